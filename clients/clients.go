@@ -0,0 +1,312 @@
+// Package clients lists devices connected to the router's LAN. Wi-Fi
+// stations, DHCP leases and the kernel neighbor table are merged into one
+// entry per MAC address.
+package clients
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	l "github.com/charmbracelet/log"
+	"github.com/zarinit-routers/cli/iw"
+	"github.com/zarinit-routers/cli/nmcli"
+	"github.com/zarinit-routers/cli/oui"
+)
+
+var log *l.Logger
+
+func init() {
+	log = l.WithPrefix("CLI clients")
+}
+
+const (
+	DefaultInterval    = 10 * time.Second
+	DefaultAwayTimeout = time.Minute
+)
+
+type Client struct {
+	MAC    string `json:"mac"`
+	Vendor string `json:"vendor,omitempty"`
+	// Locally administered MAC, e.g. private address of a phone
+	Randomized bool `json:"randomized"`
+	Wireless   bool `json:"wireless"`
+	// Wi-Fi interface for wireless clients, otherwise device client is seen on
+	Interface string   `json:"interface"`
+	IPs       []string `json:"ips"`
+	// Name client sent in DHCP request
+	Hostname string `json:"hostname"`
+	// Wi-Fi only, signal in dBm
+	Signal    int    `json:"signal"`
+	TxBitrate string `json:"txBitrate"`
+	RxBitrate string `json:"rxBitrate"`
+	// Zero without DHCP lease or for infinite one
+	LeaseExpires time.Time `json:"leaseExpires"`
+	FirstSeen    time.Time `json:"firstSeen"`
+	LastSeen     time.Time `json:"lastSeen"`
+}
+
+type Config struct {
+	// LAN devices, e.g. bridge and access point interfaces. Defaults to devices
+	// of shared connections and interfaces in AP mode.
+	Interfaces []string
+	// Zero values are replaced with defaults
+	Interval time.Duration
+	// Client which wasn't seen for this long has left
+	AwayTimeout time.Duration
+}
+
+type EventType = string
+
+const (
+	EventTypeJoined EventType = "joined"
+	EventTypeLeft   EventType = "left"
+)
+
+type Event struct {
+	Type   EventType `json:"type"`
+	Time   time.Time `json:"time"`
+	Client Client    `json:"client"`
+}
+
+// Keeps clients seen recently, so first and last seen times survive between
+// refreshes
+type Inventory struct {
+	config  Config
+	mutex   sync.Mutex
+	clients map[string]*Client
+	// Whether active connection shares its subnet, keyed by UUID and device.
+	// Method of an active connection doesn't change until it is reactivated.
+	shared map[string]bool
+}
+
+func New(config Config) *Inventory {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.AwayTimeout <= 0 {
+		config.AwayTimeout = DefaultAwayTimeout
+	}
+	return &Inventory{config: config, clients: map[string]*Client{}, shared: map[string]bool{}}
+}
+
+// Returns current clients once, without tracking them
+func List() ([]Client, error) {
+	inventory := New(Config{})
+	if _, err := inventory.Refresh(); err != nil {
+		return nil, err
+	}
+	return inventory.Clients(), nil
+}
+
+// Returns known clients sorted by MAC address
+func (i *Inventory) Clients() []Client {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	clients := []Client{}
+	for _, client := range i.clients {
+		clients = append(clients, *client)
+	}
+	sort.Slice(clients, func(a, b int) bool { return clients[a].MAC < clients[b].MAC })
+	return clients
+}
+
+// Collects clients from all sources and returns those who joined or left
+func (i *Inventory) Refresh() ([]Event, error) {
+	seen, err := i.collect()
+	if err != nil {
+		return nil, err
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	now := time.Now()
+	events := []Event{}
+	for mac, client := range seen {
+		client.LastSeen = now
+		if known, ok := i.clients[mac]; ok {
+			client.FirstSeen = known.FirstSeen
+		} else {
+			client.FirstSeen = now
+			events = append(events, Event{Type: EventTypeJoined, Time: now, Client: *client})
+		}
+		i.clients[mac] = client
+	}
+	for mac, client := range i.clients {
+		if now.Sub(client.LastSeen) > i.config.AwayTimeout {
+			delete(i.clients, mac)
+			events = append(events, Event{Type: EventTypeLeft, Time: now, Client: *client})
+		}
+	}
+	return events, nil
+}
+
+// Refreshes clients every interval until ctx is done and reports clients who
+// joined or left. Channel is closed when ctx is done.
+func (i *Inventory) Watch(ctx context.Context) <-chan Event {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(i.config.Interval)
+		defer ticker.Stop()
+		for {
+			changes, err := i.Refresh()
+			if err != nil {
+				log.Warn("Failed refresh clients", "error", err)
+			}
+			for _, event := range changes {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return events
+}
+
+// Returns clients present right now keyed by MAC address. Clients are present
+// if they are associated Wi-Fi stations or have live neighbor entries, leases
+// outlive clients and only add addresses and hostnames.
+func (i *Inventory) collect() (map[string]*Client, error) {
+	interfaces := i.config.Interfaces
+	apInterfaces, err := accessPointInterfaces(interfaces)
+	if err != nil {
+		log.Warn("Failed list Wi-Fi interfaces", "error", err)
+	}
+	leases, err := nmcli.GetAllDHCPLeases()
+	if err != nil {
+		log.Warn("Failed read DHCP leases", "error", err)
+	}
+	if len(interfaces) == 0 {
+		shared, err := i.sharedInterfaces()
+		if err != nil {
+			log.Warn("Failed list shared connections", "error", err)
+		}
+		interfaces = append(shared, apInterfaces...)
+	}
+	neighbors, err := getNeighbors()
+	if err != nil {
+		return nil, err
+	}
+
+	clients := map[string]*Client{}
+	client := func(mac, device string) *Client {
+		mac = strings.ToLower(mac)
+		if _, ok := clients[mac]; !ok {
+			clients[mac] = &Client{
+				MAC:        mac,
+				Vendor:     oui.Vendor(mac),
+				Randomized: oui.IsRandomized(mac),
+				Interface:  device,
+				IPs:        []string{},
+			}
+		}
+		return clients[mac]
+	}
+
+	for _, device := range apInterfaces {
+		stations, err := iw.GetConnectedDevices(device)
+		if err != nil {
+			log.Warn("Failed get Wi-Fi stations", "interface", device, "error", err)
+			continue
+		}
+		for _, station := range stations {
+			c := client(station.MAC, device)
+			c.Wireless, c.Interface = true, device
+			c.Signal, c.TxBitrate, c.RxBitrate = station.Signal, station.TxBitrate, station.RxBitrate
+		}
+	}
+	for _, n := range neighbors {
+		if !n.present(i.config.AwayTimeout) || !slices.Contains(interfaces, n.Device) {
+			continue
+		}
+		c := client(n.MAC, n.Device)
+		c.IPs = appendUnique(c.IPs, n.IP)
+	}
+
+	for _, deviceLeases := range leases {
+		for _, lease := range deviceLeases {
+			c, ok := clients[lease.MAC.String()]
+			if !ok {
+				continue
+			}
+			c.IPs = appendUnique(c.IPs, lease.IP.String())
+			c.Hostname, c.LeaseExpires = lease.Hostname, lease.Expires
+		}
+	}
+	for _, c := range clients {
+		sort.Strings(c.IPs)
+	}
+	return clients, nil
+}
+
+// Returns devices of active connections sharing their subnet with clients.
+// Only connections activated since the previous call are looked up.
+func (i *Inventory) sharedInterfaces() ([]string, error) {
+	connections, err := nmcli.GetConnections()
+	if err != nil {
+		return nil, err
+	}
+	i.mutex.Lock()
+	previous := i.shared
+	i.mutex.Unlock()
+
+	current := map[string]bool{}
+	devices := []string{}
+	for _, c := range connections {
+		if c.Device == "" {
+			continue
+		}
+		key := c.UUID + "/" + c.Device
+		shared, ok := previous[key]
+		if !ok {
+			conn, err := nmcli.GetConnection(c.UUID)
+			if err != nil {
+				log.Warn("Failed get connection", "connection", c.Name, "error", err)
+				continue
+			}
+			shared = conn.GetIP4Method() == nmcli.ConnectionIP4MethodShared
+		}
+		current[key] = shared
+		if shared {
+			devices = append(devices, c.Device)
+		}
+	}
+
+	i.mutex.Lock()
+	i.shared = current
+	i.mutex.Unlock()
+	return devices, nil
+}
+
+// Returns AP mode interfaces, only listed ones unless the list is empty
+func accessPointInterfaces(only []string) ([]string, error) {
+	all, err := iw.GetInterfaces("")
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, iface := range all {
+		if iface.Type == iw.InterfaceTypeAP && (len(only) == 0 || slices.Contains(only, iface.Name)) {
+			names = append(names, iface.Name)
+		}
+	}
+	return names, nil
+}
+
+func appendUnique(values []string, value string) []string {
+	if slices.Contains(values, value) {
+		return values
+	}
+	return append(values, value)
+}
@@ -0,0 +1,60 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/zarinit-routers/cli"
+)
+
+// Entry of the kernel neighbor (ARP and NDP) table
+type neighbor struct {
+	IP     string   `json:"dst"`
+	Device string   `json:"dev"`
+	MAC    string   `json:"lladdr"`
+	States []string `json:"state"`
+	// Seconds since reachability was confirmed
+	Confirmed *int `json:"confirmed"`
+}
+
+// States of entries which were recently confirmed or are being confirmed.
+// FAILED and INCOMPLETE entries belong to hosts which didn't answer.
+var presentNeighborStates = []string{"REACHABLE", "DELAY", "PROBE", "PERMANENT"}
+
+// Kernel keeps STALE entries of hosts which are long gone while the table is
+// small, they count only if confirmed recently
+const neighborStateStale = "STALE"
+
+func getNeighbors() ([]neighbor, error) {
+	output, err := cli.Execute("ip", "-json", "-statistics", "neigh", "show")
+	if err != nil {
+		return nil, fmt.Errorf("failed get neighbors: %s", err)
+	}
+	return parseNeighbors(output)
+}
+
+func parseNeighbors(output []byte) ([]neighbor, error) {
+	neighbors := []neighbor{}
+	if err := json.Unmarshal(output, &neighbors); err != nil {
+		return nil, fmt.Errorf("failed parse neighbors: %s", err)
+	}
+	return neighbors, nil
+}
+
+// Stale entries are present if they were confirmed less than maxAge ago
+func (n *neighbor) present(maxAge time.Duration) bool {
+	if n.MAC == "" {
+		return false
+	}
+	for _, state := range n.States {
+		if slices.Contains(presentNeighborStates, state) {
+			return true
+		}
+		if state == neighborStateStale && n.Confirmed != nil && time.Duration(*n.Confirmed)*time.Second < maxAge {
+			return true
+		}
+	}
+	return false
+}
@@ -2,8 +2,11 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"os"
 	"os/exec"
+	"strings"
 
 	l "github.com/charmbracelet/log"
 )
@@ -14,8 +17,28 @@ func init() {
 	log = l.Default().WithPrefix("CLI")
 }
 
+// Builds shell command line, arguments are quoted so bash passes them as is
 func wrapCommand(command string, args ...string) string {
-	return exec.Command(command, args...).String()
+	words := []string{shellQuote(command)}
+	for _, arg := range args {
+		words = append(words, shellQuote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+func shellQuote(word string) string {
+	if word != "" && strings.IndexFunc(word, isUnsafeShellRune) < 0 {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+func isUnsafeShellRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	}
+	return !strings.ContainsRune("-_./=:,@%+", r)
 }
 
 func execute(stdin *bytes.Buffer, command string, args ...string) ([]byte, int, error) {
@@ -64,3 +87,23 @@ func ExecuteErr(command string, args ...string) error {
 	_, _, err := execute(nil, command, args...)
 	return err
 }
+
+// Start specified command without waiting for it to finish. Process is killed
+// when ctx is done, its output can be read until it exits.
+func Start(ctx context.Context, command string, args ...string) (*exec.Cmd, io.ReadCloser, error) {
+	wrapped := wrapCommand(command, args...)
+	log.Debug("Wrapped command", "command", wrapped)
+	// exec replaces the shell, so killing the process kills the command itself
+	cmd := exec.CommandContext(ctx, "bash", "--norc", "-c", "exec "+wrapped)
+	cmd.Env = os.Environ()
+
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		log.Warn("Error while starting command", "command", cmd.String(), "error", err)
+		return nil, nil, err
+	}
+	return cmd, output, nil
+}
@@ -0,0 +1,341 @@
+// Package failover keeps traffic on the best working WAN uplink. Uplinks are
+// probed periodically and traffic is moved to a backup when the preferred one
+// fails and back when it recovers.
+package failover
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	l "github.com/charmbracelet/log"
+	"github.com/zarinit-routers/cli/nmcli"
+)
+
+var log *l.Logger
+
+func init() {
+	log = l.WithPrefix("CLI failover")
+}
+
+type Mode = string
+
+const (
+	// All uplinks stay connected, the active one gets the lowest route metric
+	ModeMetric Mode = "metric"
+	// Uplinks less preferred than the active one are deactivated, which suits
+	// metered backups like LTE. Preferred uplinks stay connected to be probed.
+	ModeActivate Mode = "activate"
+)
+
+const (
+	DefaultProbeTarget      = "1.1.1.1"
+	DefaultInterval         = 5 * time.Second
+	DefaultProbeTimeout     = 2 * time.Second
+	DefaultFailThreshold    = 3
+	DefaultRecoverThreshold = 5
+	DefaultActiveMetric     = 100
+	DefaultStandbyMetric    = 1000
+)
+
+type Uplink struct {
+	// Name of NetworkManager connection
+	Connection string `json:"connection"`
+	// Address pinged through the uplink, defaults to [DefaultProbeTarget]
+	ProbeTarget string `json:"probeTarget"`
+}
+
+type Config struct {
+	// Uplinks in order of preference, the first one is primary
+	Uplinks []Uplink
+	Mode    Mode
+	// Zero values are replaced with defaults
+	Interval     time.Duration
+	ProbeTimeout time.Duration
+	// Consecutive failed probes after which uplink is considered down
+	FailThreshold int
+	// Consecutive successful probes after which uplink is considered up again
+	RecoverThreshold int
+	// Route metric of the active uplink, standby ones get StandbyMetric plus
+	// their position in Uplinks
+	ActiveMetric  int
+	StandbyMetric int
+}
+
+type EventType = string
+
+const (
+	EventTypeUplinkDown EventType = "uplink-down"
+	EventTypeUplinkUp   EventType = "uplink-up"
+	// Traffic moved to a less preferred uplink
+	EventTypeFailover EventType = "failover"
+	// Traffic moved back to a more preferred uplink
+	EventTypeFailback EventType = "failback"
+	EventTypeAllDown  EventType = "all-down"
+	// Switching uplinks failed, it is retried on the next probe
+	EventTypeSwitchFailed EventType = "switch-failed"
+)
+
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// Uplink the event is about, new active one for failover and failback
+	Uplink string `json:"uplink,omitempty"`
+	// Previously active uplink for failover and failback
+	Previous string `json:"previous,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type UplinkStatus struct {
+	Connection string `json:"connection"`
+	Healthy    bool   `json:"healthy"`
+	Active     bool   `json:"active"`
+	// Reason of the last failed probe
+	LastError string `json:"lastError,omitempty"`
+}
+
+type uplinkState struct {
+	Uplink
+	healthy   bool
+	failures  int
+	successes int
+	lastError string
+}
+
+type Controller struct {
+	config  Config
+	mutex   sync.Mutex
+	uplinks []*uplinkState
+	// Index of active uplink, -1 until the first switch
+	active  int
+	allDown bool
+}
+
+func New(config Config) (*Controller, error) {
+	if len(config.Uplinks) == 0 {
+		return nil, fmt.Errorf("at least one uplink is required")
+	}
+	switch config.Mode {
+	case "":
+		config.Mode = ModeMetric
+	case ModeMetric, ModeActivate:
+	default:
+		return nil, fmt.Errorf("unknown failover mode %q", config.Mode)
+	}
+	setDefault(&config.FailThreshold, DefaultFailThreshold)
+	setDefault(&config.RecoverThreshold, DefaultRecoverThreshold)
+	setDefault(&config.ActiveMetric, DefaultActiveMetric)
+	setDefault(&config.StandbyMetric, DefaultStandbyMetric)
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.ProbeTimeout <= 0 {
+		config.ProbeTimeout = DefaultProbeTimeout
+	}
+
+	c := &Controller{config: config, active: -1}
+	seen := map[string]bool{}
+	for _, uplink := range config.Uplinks {
+		if seen[uplink.Connection] {
+			return nil, fmt.Errorf("duplicate uplink %q", uplink.Connection)
+		}
+		seen[uplink.Connection] = true
+		if uplink.ProbeTarget == "" {
+			uplink.ProbeTarget = DefaultProbeTarget
+		}
+		// Uplinks are trusted until probes prove otherwise
+		c.uplinks = append(c.uplinks, &uplinkState{Uplink: uplink, healthy: true})
+	}
+	return c, nil
+}
+
+func setDefault(value *int, defaultValue int) {
+	if *value <= 0 {
+		*value = defaultValue
+	}
+}
+
+// Probes uplinks and switches between them until ctx is done. Channel is
+// closed when ctx is done, events are dropped if nobody reads them.
+func (c *Controller) Run(ctx context.Context) <-chan Event {
+	events := make(chan Event, 16)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(c.config.Interval)
+		defer ticker.Stop()
+		for {
+			for _, event := range c.Step() {
+				select {
+				case events <- event:
+				default:
+					log.Warn("Dropping failover event, nobody reads them", "type", event.Type)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return events
+}
+
+// Probes all uplinks once and switches active uplink if needed
+func (c *Controller) Step() []Event {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	events := []Event{}
+	for i, uplink := range c.uplinks {
+		// Deactivated standby uplinks can't be probed and keep their health
+		if c.config.Mode == ModeActivate && c.active >= 0 && i > c.active {
+			continue
+		}
+		err := c.probe(uplink.Uplink)
+		if event := uplink.record(err, c.config); event != nil {
+			events = append(events, *event)
+		}
+	}
+
+	best := -1
+	for i, uplink := range c.uplinks {
+		if uplink.healthy {
+			best = i
+			break
+		}
+	}
+	if best == -1 {
+		// Traffic stays where it is, there is nowhere to move it
+		if !c.allDown {
+			events = append(events, newEvent(EventTypeAllDown, "", "", "no healthy uplinks"))
+			c.allDown = true
+		}
+		return events
+	}
+	c.allDown = false
+	if best == c.active {
+		return events
+	}
+
+	previous := ""
+	if c.active >= 0 {
+		previous = c.uplinks[c.active].Connection
+	}
+	if err := c.switchTo(best); err != nil {
+		return append(events, newEvent(EventTypeSwitchFailed, c.uplinks[best].Connection, previous, err.Error()))
+	}
+	eventType := EventTypeFailover
+	if c.active >= 0 && best < c.active {
+		eventType = EventTypeFailback
+	}
+	c.active = best
+	if previous != "" {
+		events = append(events, newEvent(eventType, c.uplinks[best].Connection, previous, ""))
+	}
+	return events
+}
+
+// Updates uplink health, returns event if it changed
+func (u *uplinkState) record(probeErr error, config Config) *Event {
+	if probeErr == nil {
+		u.failures = 0
+		u.successes++
+		if !u.healthy && u.successes >= config.RecoverThreshold {
+			u.healthy = true
+			event := newEvent(EventTypeUplinkUp, u.Connection, "", "")
+			return &event
+		}
+		return nil
+	}
+
+	log.Debug("Uplink probe failed", "uplink", u.Connection, "error", probeErr)
+	u.lastError = probeErr.Error()
+	u.successes = 0
+	u.failures++
+	if u.healthy && u.failures >= config.FailThreshold {
+		u.healthy = false
+		event := newEvent(EventTypeUplinkDown, u.Connection, "", u.lastError)
+		return &event
+	}
+	return nil
+}
+
+// Moves traffic to uplink with index
+func (c *Controller) switchTo(index int) error {
+	log.Info("Switching uplink", "uplink", c.uplinks[index].Connection)
+	for i, uplink := range c.uplinks {
+		conn, err := nmcli.GetConnection(uplink.Connection)
+		if err != nil {
+			return err
+		}
+		switch c.config.Mode {
+		case ModeMetric:
+			metric := c.config.ActiveMetric
+			if i != index {
+				metric = c.config.StandbyMetric + i
+			}
+			if err := setMetric(conn, metric); err != nil {
+				return fmt.Errorf("failed set metric of %q: %s", uplink.Connection, err)
+			}
+		case ModeActivate:
+			if i <= index && !conn.IsActive() {
+				if err := conn.Up(); err != nil {
+					return fmt.Errorf("failed activate %q: %s", uplink.Connection, err)
+				}
+			}
+			if i > index && conn.IsActive() {
+				if err := conn.Down(); err != nil {
+					return fmt.Errorf("failed deactivate %q: %s", uplink.Connection, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Sets route metric of both IP families and applies it to the active connection
+func setMetric(conn *nmcli.Connection, metric int) error {
+	if conn.GetRouteMetric(nmcli.IPFamily4) == metric && conn.GetRouteMetric(nmcli.IPFamily6) == metric {
+		return nil
+	}
+	for _, family := range []nmcli.IPFamily{nmcli.IPFamily4, nmcli.IPFamily6} {
+		if err := conn.SetRouteMetric(family, metric); err != nil {
+			return err
+		}
+	}
+	if !conn.IsActive() {
+		return nil
+	}
+	return nmcli.ReapplyDevice(conn.GetActiveDevice())
+}
+
+// Returns active uplink connection, empty before the first probe
+func (c *Controller) Active() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.active < 0 {
+		return ""
+	}
+	return c.uplinks[c.active].Connection
+}
+
+func (c *Controller) Status() []UplinkStatus {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	statuses := []UplinkStatus{}
+	for i, uplink := range c.uplinks {
+		statuses = append(statuses, UplinkStatus{
+			Connection: uplink.Connection,
+			Healthy:    uplink.healthy,
+			Active:     i == c.active,
+			LastError:  uplink.lastError,
+		})
+	}
+	return statuses
+}
+
+func newEvent(t EventType, uplink, previous, reason string) Event {
+	return Event{Type: t, Time: time.Now(), Uplink: uplink, Previous: previous, Reason: reason}
+}
@@ -0,0 +1,46 @@
+package failover
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/zarinit-routers/cli"
+	"github.com/zarinit-routers/cli/nmcli"
+)
+
+// Checks link state, connectivity detected by NetworkManager and reachability
+// of probe target through the uplink device
+func (c *Controller) probe(uplink Uplink) error {
+	conn, err := nmcli.GetConnection(uplink.Connection)
+	if err != nil {
+		return err
+	}
+	if !conn.IsActive() {
+		return fmt.Errorf("connection is not active")
+	}
+	device := conn.GetActiveDevice()
+	dev, err := nmcli.GetDevice(device)
+	if err != nil {
+		return err
+	}
+	if !dev.IsConnected() {
+		_, state := dev.GetState()
+		return fmt.Errorf("device %s is %s", device, state)
+	}
+	switch connectivity := dev.GetIP4Connectivity(); connectivity {
+	case nmcli.ConnectivityNone, nmcli.ConnectivityPortal, nmcli.ConnectivityLimited:
+		return fmt.Errorf("NetworkManager reports %s connectivity on %s", connectivity, device)
+	}
+	return ping(device, uplink.ProbeTarget, c.config.ProbeTimeout)
+}
+
+// Sends single ping bound to device, so it leaves through that uplink
+func ping(device, target string, timeout time.Duration) error {
+	seconds := max(1, int(timeout.Round(time.Second)/time.Second))
+	err := cli.ExecuteErr("ping", "-n", "-q", "-c", "1", "-W", strconv.Itoa(seconds), "-I", device, target)
+	if err != nil {
+		return fmt.Errorf("%s is unreachable through %s: %s", target, device, err)
+	}
+	return nil
+}
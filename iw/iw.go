@@ -2,10 +2,13 @@ package iw
 
 import (
 	"errors"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/zarinit-routers/cli"
+	"github.com/zarinit-routers/cli/oui"
 )
 
 type ConnectedDevice struct {
@@ -13,6 +16,13 @@ type ConnectedDevice struct {
 	Interface string `json:"interface"`
 	TxBitrate string `json:"txBitrate"`
 	RxBitrate string `json:"rxBitrate"`
+	// dBm, zero if unknown
+	Signal        int           `json:"signal"`
+	ConnectedTime time.Duration `json:"connectedTime"`
+	// Empty if MAC is unknown or randomized
+	Vendor string `json:"vendor,omitempty"`
+	// Locally administered MAC, e.g. private address of a phone
+	Randomized bool `json:"randomized"`
 }
 
 func GetConnectedDevices(device string) ([]ConnectedDevice, error) {
@@ -58,6 +68,14 @@ func parseDevice(block string) (*ConnectedDevice, error) {
 	dict := parseLines(lines[1:])
 	device.RxBitrate = dict["rx bitrate"]
 	device.TxBitrate = dict["tx bitrate"]
+	// e.g. "-42 [-44, -45] dBm" and "125 seconds"
+	if fields := strings.Fields(dict["signal"]); len(fields) > 0 {
+		device.Signal, _ = strconv.Atoi(fields[0])
+	}
+	if fields := strings.Fields(dict["connected time"]); len(fields) > 0 {
+		seconds, _ := strconv.Atoi(fields[0])
+		device.ConnectedTime = time.Duration(seconds) * time.Second
+	}
 	return &device, nil
 }
 
@@ -84,6 +102,8 @@ func parseFirstLine(line string) (ConnectedDevice, error) {
 		return device, ErrBadFirstLine
 	}
 	device.MAC = words[0]
+	device.Vendor = oui.Vendor(device.MAC)
+	device.Randomized = oui.IsRandomized(device.MAC)
 	device.Interface = strings.Replace(words[2], ")", "", 1)
 	return device, nil
 }
@@ -0,0 +1,296 @@
+package iw
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/zarinit-routers/cli"
+)
+
+type InterfaceType = string
+
+const (
+	InterfaceTypeManaged InterfaceType = "managed"
+	InterfaceTypeAP      InterfaceType = "AP"
+	InterfaceTypeMesh    InterfaceType = "mesh point"
+	InterfaceTypeMonitor InterfaceType = "monitor"
+	InterfaceTypeIBSS    InterfaceType = "IBSS"
+)
+
+// Names of interface types accepted by `iw interface add`
+var interfaceTypeArgs = map[InterfaceType]string{
+	InterfaceTypeManaged: "managed",
+	InterfaceTypeAP:      "__ap",
+	InterfaceTypeMesh:    "mp",
+	InterfaceTypeMonitor: "monitor",
+	InterfaceTypeIBSS:    "ibss",
+}
+
+// At most Max interfaces of listed types may exist at once
+type InterfaceLimit struct {
+	Max   int             `json:"max"`
+	Types []InterfaceType `json:"types"`
+}
+
+// One of the sets of interfaces the phy can run simultaneously
+type InterfaceCombination struct {
+	Limits   []InterfaceLimit `json:"limits"`
+	Total    int              `json:"total"`
+	Channels int              `json:"channels"` // Different channels interfaces may use
+}
+
+type Interface struct {
+	Name      string        `json:"name"`
+	Type      InterfaceType `json:"type"`
+	MAC       string        `json:"mac"`
+	Phy       string        `json:"phy"`
+	Channel   int           `json:"channel"`
+	Frequency int           `json:"frequency"` // MHz
+	TxPower   float64       `json:"txPower"`   // dBm
+}
+
+var ErrNoCombination = errors.New("no valid interface combination")
+
+// Returns phy name of the device, e.g. phy0
+func GetPhy(device string) (string, error) {
+	info, err := GetInterface(device)
+	if err != nil {
+		return "", err
+	}
+	return info.Phy, nil
+}
+
+func GetInterface(device string) (*Interface, error) {
+	output, err := cli.Execute("iw", "dev", device, "info")
+	if err != nil {
+		return nil, err
+	}
+	iface := parseInterfaceInfo(string(output))
+	if iface.Phy == "" {
+		return nil, fmt.Errorf("no phy in info of device %q", device)
+	}
+	return &iface, nil
+}
+
+func parseInterfaceInfo(output string) Interface {
+	iface := Interface{}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch key {
+		case "Interface":
+			iface.Name = value
+		case "type":
+			iface.Type = value
+		case "addr":
+			iface.MAC = value
+		case "wiphy":
+			iface.Phy = "phy" + value
+		case "channel":
+			// channel 6 (2437 MHz), width: 20 MHz, center1: 2437 MHz
+			fmt.Sscanf(value, "%d (%d MHz)", &iface.Channel, &iface.Frequency)
+		case "txpower":
+			fmt.Sscanf(value, "%f dBm", &iface.TxPower)
+		}
+	}
+	return iface
+}
+
+// Lists interfaces of the phy, empty phy lists interfaces of all phys
+func GetInterfaces(phy string) ([]Interface, error) {
+	output, err := cli.Execute("iw", "dev")
+	if err != nil {
+		return nil, err
+	}
+	return filterInterfaces(parseInterfaces(string(output)), phy), nil
+}
+
+func filterInterfaces(all []Interface, phy string) []Interface {
+	interfaces := []Interface{}
+	for _, iface := range all {
+		if phy == "" || iface.Phy == phy {
+			interfaces = append(interfaces, iface)
+		}
+	}
+	return interfaces
+}
+
+// Parses `iw dev` output, where interfaces are grouped by phy#N lines
+func parseInterfaces(output string) []Interface {
+	interfaces := []Interface{}
+	phy := ""
+	for _, block := range strings.Split(output, "\n") {
+		line := strings.TrimSpace(block)
+		switch {
+		case strings.HasPrefix(line, "phy#"):
+			phy = "phy" + strings.TrimPrefix(line, "phy#")
+		case strings.HasPrefix(line, "Interface "):
+			interfaces = append(interfaces, Interface{
+				Name: strings.TrimPrefix(line, "Interface "),
+				Phy:  phy,
+			})
+		case len(interfaces) > 0:
+			key, value, _ := strings.Cut(line, " ")
+			switch key {
+			case "type":
+				interfaces[len(interfaces)-1].Type = value
+			case "addr":
+				interfaces[len(interfaces)-1].MAC = value
+			}
+		}
+	}
+	return interfaces
+}
+
+func GetInterfaceCombinations(phy string) ([]InterfaceCombination, error) {
+	output, err := cli.Execute("iw", "phy", phy, "info")
+	if err != nil {
+		return nil, err
+	}
+	return parseInterfaceCombinations(string(output)), nil
+}
+
+var (
+	limitRegex    = regexp.MustCompile(`#\{\s*([^}]*?)\s*\}\s*<=\s*(\d+)`)
+	totalRegex    = regexp.MustCompile(`total\s*<=\s*(\d+)`)
+	channelsRegex = regexp.MustCompile(`#channels\s*<=\s*(\d+)`)
+)
+
+const combinationsHeader = "valid interface combinations:"
+
+// Parses "valid interface combinations" section of `iw phy` output
+func parseInterfaceCombinations(output string) []InterfaceCombination {
+	entries := []string{}
+	inSection := false
+	sectionIndent := 0
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+
+		if strings.HasPrefix(trimmed, combinationsHeader) {
+			inSection = true
+			sectionIndent = indent
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if trimmed == "" || indent <= sectionIndent {
+			break
+		}
+		if strings.HasPrefix(trimmed, "*") {
+			entries = append(entries, strings.TrimPrefix(trimmed, "*"))
+		} else if len(entries) > 0 {
+			entries[len(entries)-1] += " " + trimmed
+		}
+	}
+
+	combinations := []InterfaceCombination{}
+	for _, entry := range entries {
+		combination, err := parseInterfaceCombination(entry)
+		if err != nil {
+			log.Warnf("Failed parsing interface combination %q: %s", entry, err)
+			continue
+		}
+		combinations = append(combinations, combination)
+	}
+	return combinations
+}
+
+func parseInterfaceCombination(entry string) (InterfaceCombination, error) {
+	combination := InterfaceCombination{Channels: 1}
+	for _, match := range limitRegex.FindAllStringSubmatch(entry, -1) {
+		max, _ := strconv.Atoi(match[2])
+		types := []InterfaceType{}
+		for _, t := range strings.Split(match[1], ",") {
+			types = append(types, strings.TrimSpace(t))
+		}
+		combination.Limits = append(combination.Limits, InterfaceLimit{Max: max, Types: types})
+	}
+	if len(combination.Limits) == 0 {
+		return combination, errors.New("no interface limits")
+	}
+	if match := totalRegex.FindStringSubmatch(entry); match != nil {
+		combination.Total, _ = strconv.Atoi(match[1])
+	}
+	if match := channelsRegex.FindStringSubmatch(entry); match != nil {
+		combination.Channels, _ = strconv.Atoi(match[1])
+	}
+	return combination, nil
+}
+
+// Reports whether interfaces of specified types may run at once
+func (c *InterfaceCombination) Allows(types []InterfaceType) bool {
+	if c.Total > 0 && len(types) > c.Total {
+		return false
+	}
+	counts := make([]int, len(c.Limits))
+	for _, t := range types {
+		found := false
+		for i, limit := range c.Limits {
+			for _, limitType := range limit.Types {
+				if limitType == t {
+					counts[i]++
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for i, limit := range c.Limits {
+		if counts[i] > limit.Max {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks that interface of specified type can be added next to existing
+// interfaces of the phy, returns the combination allowing it
+func CanAddInterface(phy string, t InterfaceType) (*InterfaceCombination, error) {
+	combinations, err := GetInterfaceCombinations(phy)
+	if err != nil {
+		return nil, err
+	}
+	interfaces, err := GetInterfaces(phy)
+	if err != nil {
+		return nil, err
+	}
+
+	types := []InterfaceType{t}
+	for _, iface := range interfaces {
+		types = append(types, iface.Type)
+	}
+	for _, combination := range combinations {
+		if combination.Allows(types) {
+			return &combination, nil
+		}
+	}
+	return nil, fmt.Errorf("%w for %v on %s", ErrNoCombination, types, phy)
+}
+
+// Adds virtual interface to the phy. Empty MAC keeps the phy address.
+func AddInterface(phy, name string, t InterfaceType, mac string) error {
+	typeArg, ok := interfaceTypeArgs[t]
+	if !ok {
+		return fmt.Errorf("unsupported interface type %q", t)
+	}
+	args := []string{"phy", phy, "interface", "add", name, "type", typeArg}
+	if mac != "" {
+		args = append(args, "addr", mac)
+	}
+	return cli.ExecuteErr("iw", args...)
+}
+
+func DeleteInterface(name string) error {
+	return cli.ExecuteErr("iw", "dev", name, "del")
+}
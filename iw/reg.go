@@ -0,0 +1,247 @@
+package iw
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/zarinit-routers/cli"
+)
+
+type Band = string
+
+const (
+	Band2GHz Band = "2.4GHz"
+	Band5GHz Band = "5GHz"
+	Band6GHz Band = "6GHz"
+)
+
+// Country code of the world regulatory domain
+const CountryWorld = "00"
+
+const (
+	RegulatoryFlagDFS  = "DFS"
+	RegulatoryFlagNoIR = "NO-IR" // Initiating radiation, e.g. running access point, is not allowed
+)
+
+// Frequency range with its limits, frequencies are in MHz
+type RegulatoryRule struct {
+	StartFrequency float64  `json:"startFrequency"`
+	EndFrequency   float64  `json:"endFrequency"`
+	MaxBandwidth   float64  `json:"maxBandwidth"`
+	MaxEIRP        float64  `json:"maxEirp"` // dBm
+	CACTime        int      `json:"cacTime"` // Channel availability check, ms
+	Flags          []string `json:"flags"`
+}
+
+func (r *RegulatoryRule) HasFlag(flag string) bool {
+	for _, f := range r.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+type RegulatoryDomain struct {
+	// Empty for global domain
+	Phy         string           `json:"phy"`
+	SelfManaged bool             `json:"selfManaged"`
+	Country     string           `json:"country"`
+	DFSRegion   string           `json:"dfsRegion"`
+	Rules       []RegulatoryRule `json:"rules"`
+}
+
+// Returns global domain followed by domains of phys which have their own
+func GetRegulatoryDomains() ([]RegulatoryDomain, error) {
+	output, err := cli.Execute("iw", "reg", "get")
+	if err != nil {
+		return nil, err
+	}
+	return parseRegulatoryDomains(string(output)), nil
+}
+
+// Returns domain applied to phy, which is the global one unless phy has its own
+func GetRegulatoryDomain(phy string) (*RegulatoryDomain, error) {
+	domains, err := GetRegulatoryDomains()
+	if err != nil {
+		return nil, err
+	}
+	var global *RegulatoryDomain
+	for i, domain := range domains {
+		if domain.Phy == phy {
+			return &domains[i], nil
+		}
+		if domain.Phy == "" {
+			global = &domains[i]
+		}
+	}
+	if global == nil {
+		return nil, fmt.Errorf("no regulatory domain for %s", phy)
+	}
+	return global, nil
+}
+
+var countryRegex = regexp.MustCompile(`^([A-Z]{2}|00)$`)
+
+// Sets global regulatory domain, code is ISO 3166-1 alpha-2 country code
+func SetCountry(code string) error {
+	code = strings.ToUpper(code)
+	if !countryRegex.MatchString(code) {
+		return fmt.Errorf("invalid country code %q", code)
+	}
+	return cli.ExecuteErr("iw", "reg", "set", code)
+}
+
+var (
+	regCountryRegex = regexp.MustCompile(`^country (\S+):\s*(\S*)`)
+	regPhyRegex     = regexp.MustCompile(`^phy#(\d+)(.*)$`)
+	regRuleRegex    = regexp.MustCompile(`^\(([\d.]+) - ([\d.]+) @ ([\d.]+)\), \(([^,]+), ([^)]+)\)(?:, \(([^)]+)\))?(.*)$`)
+)
+
+func parseRegulatoryDomains(output string) []RegulatoryDomain {
+	domains := []RegulatoryDomain{}
+	var current *RegulatoryDomain
+	phy := ""
+	selfManaged := false
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "global":
+			phy, selfManaged = "", false
+		case regPhyRegex.MatchString(line):
+			match := regPhyRegex.FindStringSubmatch(line)
+			phy = "phy" + match[1]
+			selfManaged = strings.Contains(match[2], "self-managed")
+		case regCountryRegex.MatchString(line):
+			match := regCountryRegex.FindStringSubmatch(line)
+			domains = append(domains, RegulatoryDomain{
+				Phy:         phy,
+				SelfManaged: selfManaged,
+				Country:     match[1],
+				DFSRegion:   strings.TrimPrefix(match[2], "DFS-"),
+				Rules:       []RegulatoryRule{},
+			})
+			current = &domains[len(domains)-1]
+		case strings.HasPrefix(line, "(") && current != nil:
+			rule, err := parseRegulatoryRule(line)
+			if err != nil {
+				log.Warnf("Failed parsing regulatory rule %q: %s", line, err)
+				continue
+			}
+			current.Rules = append(current.Rules, rule)
+		}
+	}
+	return domains
+}
+
+// Parses lines like "(5250 - 5350 @ 80), (N/A, 24), (0 ms), DFS, AUTO-BW"
+func parseRegulatoryRule(line string) (RegulatoryRule, error) {
+	match := regRuleRegex.FindStringSubmatch(line)
+	if match == nil {
+		return RegulatoryRule{}, fmt.Errorf("unexpected rule format")
+	}
+	rule := RegulatoryRule{Flags: []string{}}
+	rule.StartFrequency, _ = strconv.ParseFloat(match[1], 64)
+	rule.EndFrequency, _ = strconv.ParseFloat(match[2], 64)
+	rule.MaxBandwidth, _ = strconv.ParseFloat(match[3], 64)
+	rule.MaxEIRP = parsePower(match[5])
+
+	if cac := strings.TrimSuffix(strings.TrimSpace(match[6]), " ms"); cac != "" && cac != "N/A" {
+		rule.CACTime, _ = strconv.Atoi(cac)
+	}
+	for _, flag := range strings.Split(match[7], ",") {
+		if flag = strings.TrimSpace(flag); flag != "" {
+			rule.Flags = append(rule.Flags, flag)
+		}
+	}
+	return rule, nil
+}
+
+// Parses power in dBm or mW to dBm
+func parsePower(value string) float64 {
+	value = strings.TrimSpace(value)
+	if mw, ok := strings.CutSuffix(value, " mW"); ok {
+		milliwatts, err := strconv.ParseFloat(mw, 64)
+		if err != nil || milliwatts <= 0 {
+			return 0
+		}
+		return milliwattsToDBm(milliwatts)
+	}
+	dbm, _ := strconv.ParseFloat(value, 64)
+	return dbm
+}
+
+type Channel struct {
+	Number    int     `json:"number"`
+	Frequency int     `json:"frequency"` // MHz
+	MaxEIRP   float64 `json:"maxEirp"`   // dBm
+	DFS       bool    `json:"dfs"`
+}
+
+// 20 MHz channels of each band
+var bandChannels = map[Band][]int{
+	Band2GHz: {1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14},
+	Band5GHz: {36, 40, 44, 48, 52, 56, 60, 64, 100, 104, 108, 112, 116, 120, 124, 128, 132, 136, 140, 144, 149, 153, 157, 161, 165, 169, 173, 177},
+	Band6GHz: {1, 5, 9, 13, 17, 21, 25, 29, 33, 37, 41, 45, 49, 53, 57, 61, 65, 69, 73, 77, 81, 85, 89, 93, 97, 101, 105, 109, 113, 117, 121, 125, 129, 133, 137, 141, 145, 149, 153, 157, 161, 165, 169, 173, 177, 181, 185, 189, 193, 197, 201, 205, 209, 213, 217, 221, 225, 229, 233},
+}
+
+// Returns center frequency of 20 MHz channel in MHz
+func ChannelFrequency(band Band, channel int) int {
+	switch band {
+	case Band5GHz:
+		return 5000 + 5*channel
+	case Band6GHz:
+		return 5950 + 5*channel
+	}
+	if channel == 14 {
+		return 2484
+	}
+	return 2407 + 5*channel
+}
+
+// Returns channels of the band an access point may use. NO-IR channels are
+// always excluded, DFS channels require radar detection and are optional.
+func (d *RegulatoryDomain) AllowedChannels(band Band, includeDFS bool) []Channel {
+	channels := []Channel{}
+	for _, number := range bandChannels[band] {
+		frequency := ChannelFrequency(band, number)
+		low, high := float64(frequency-10), float64(frequency+10)
+		for _, rule := range d.Rules {
+			if low < rule.StartFrequency || high > rule.EndFrequency || rule.MaxBandwidth < 20 {
+				continue
+			}
+			if rule.HasFlag(RegulatoryFlagNoIR) {
+				break
+			}
+			dfs := rule.HasFlag(RegulatoryFlagDFS)
+			if dfs && !includeDFS {
+				break
+			}
+			channels = append(channels, Channel{
+				Number:    number,
+				Frequency: frequency,
+				MaxEIRP:   rule.MaxEIRP,
+				DFS:       dfs,
+			})
+			break
+		}
+	}
+	return channels
+}
+
+func ChannelNumbers(channels []Channel) []int {
+	numbers := []int{}
+	for _, channel := range channels {
+		numbers = append(numbers, channel.Number)
+	}
+	return numbers
+}
+
+func milliwattsToDBm(milliwatts float64) float64 {
+	return 10 * math.Log10(milliwatts)
+}
@@ -0,0 +1,113 @@
+package iw
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/zarinit-routers/cli"
+)
+
+type TxPowerMode = string
+
+const (
+	TxPowerModeAuto  TxPowerMode = "auto"
+	TxPowerModeFixed TxPowerMode = "fixed"
+	TxPowerModeLimit TxPowerMode = "limit" // Driver may use less power than the limit
+)
+
+// Returns current transmit power of the interface in dBm
+func GetTxPower(device string) (float64, error) {
+	iface, err := GetInterface(device)
+	if err != nil {
+		return 0, err
+	}
+	return iface.TxPower, nil
+}
+
+// Sets transmit power of the interface in dBm, power is ignored in auto mode.
+// Power must not exceed maximum EIRP of the current channel allowed by
+// regulatory domain.
+func SetTxPower(device string, mode TxPowerMode, dBm float64) error {
+	args := []string{"dev", device, "set", "txpower", mode}
+	switch mode {
+	case TxPowerModeAuto:
+	case TxPowerModeFixed, TxPowerModeLimit:
+		if err := ValidateTxPower(device, dBm); err != nil {
+			return err
+		}
+		args = append(args, strconv.Itoa(dBmToMBm(dBm)))
+	default:
+		return fmt.Errorf("unknown transmit power mode %q", mode)
+	}
+	return cli.ExecuteErr("iw", args...)
+}
+
+// Checks power against regulatory maximum of the interface's current channel
+func ValidateTxPower(device string, dBm float64) error {
+	iface, err := GetInterface(device)
+	if err != nil {
+		return err
+	}
+	if iface.Frequency == 0 {
+		return fmt.Errorf("interface %q has no channel", device)
+	}
+	return ValidatePhyTxPower(iface.Phy, iface.Frequency, dBm)
+}
+
+// Checks power against regulatory maximum of the phy at frequency, or the
+// highest one of its regulatory domain if frequency is zero
+func ValidatePhyTxPower(phy string, frequency int, dBm float64) error {
+	if dBm <= 0 {
+		return fmt.Errorf("invalid transmit power %.2f dBm", dBm)
+	}
+	max, err := MaxRegulatoryTxPower(phy, frequency)
+	if err != nil {
+		return err
+	}
+	if dBm > max {
+		return fmt.Errorf("transmit power %.2f dBm exceeds regulatory maximum %.2f dBm", dBm, max)
+	}
+	return nil
+}
+
+// Returns maximum EIRP allowed on the current channel of the interface in dBm
+func MaxTxPower(device string) (float64, error) {
+	iface, err := GetInterface(device)
+	if err != nil {
+		return 0, err
+	}
+	if iface.Frequency == 0 {
+		return 0, fmt.Errorf("interface %q has no channel", device)
+	}
+	return MaxRegulatoryTxPower(iface.Phy, iface.Frequency)
+}
+
+// Returns maximum EIRP regulatory domain of the phy allows at frequency in
+// dBm. Zero frequency gives the highest maximum of all rules, which bounds
+// power before channel is known.
+func MaxRegulatoryTxPower(phy string, frequency int) (float64, error) {
+	domain, err := GetRegulatoryDomain(phy)
+	if err != nil {
+		return 0, err
+	}
+	max := 0.0
+	for _, rule := range domain.Rules {
+		if frequency == 0 {
+			max = math.Max(max, rule.MaxEIRP)
+			continue
+		}
+		if float64(frequency) >= rule.StartFrequency && float64(frequency) <= rule.EndFrequency {
+			return rule.MaxEIRP, nil
+		}
+	}
+	if frequency == 0 && len(domain.Rules) > 0 {
+		return max, nil
+	}
+	return 0, fmt.Errorf("frequency %d MHz is not allowed in %s regulatory domain", frequency, domain.Country)
+}
+
+// iw takes power in mBm, hundredths of dBm
+func dBmToMBm(dBm float64) int {
+	return int(dBm * 100)
+}
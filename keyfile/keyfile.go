@@ -0,0 +1,301 @@
+// Documentation for NetworkManager keyfile format:
+//
+// - https://networkmanager.dev/docs/api/latest/nm-settings-keyfile.html
+// - https://docs.gtk.org/glib/struct.KeyFile.html
+package keyfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NetworkManager refuses to load keyfiles readable by others
+const FileMode os.FileMode = 0o600
+
+// INI file with groups of key-value pairs, order of groups and keys is kept
+type File struct {
+	groups []*Group
+}
+
+type Group struct {
+	Name    string
+	entries []entry
+}
+
+type entry struct {
+	key string
+	// Value as it is written in the file, with escape sequences
+	raw string
+}
+
+func New() *File {
+	return &File{}
+}
+
+func Read(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed parse %s: %s", path, err)
+	}
+	return f, nil
+}
+
+func Parse(data []byte) (*File, error) {
+	f := New()
+	var group *Group
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated group name", n)
+			}
+			group = f.AddGroup(line[1 : len(line)-1])
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: no '=' in %q", n, line)
+			}
+			if group == nil {
+				return nil, fmt.Errorf("line %d: key outside of group", n)
+			}
+			group.setRaw(strings.TrimSpace(key), strings.TrimLeft(value, " \t"))
+		}
+	}
+	return f, scanner.Err()
+}
+
+func (f *File) Groups() []*Group {
+	return f.groups
+}
+
+// Returns group with name, nil if there is none
+func (f *File) Group(name string) *Group {
+	for _, g := range f.groups {
+		if g.Name == name {
+			return g
+		}
+	}
+	return nil
+}
+
+// Returns existing group with name or appends a new one
+func (f *File) AddGroup(name string) *Group {
+	if g := f.Group(name); g != nil {
+		return g
+	}
+	g := &Group{Name: name}
+	f.groups = append(f.groups, g)
+	return g
+}
+
+func (f *File) DeleteGroup(name string) {
+	for i, g := range f.groups {
+		if g.Name == name {
+			f.groups = append(f.groups[:i], f.groups[i+1:]...)
+			return
+		}
+	}
+}
+
+func (f *File) Bytes() []byte {
+	var b bytes.Buffer
+	for i, g := range f.groups {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[%s]\n", g.Name)
+		for _, e := range g.entries {
+			fmt.Fprintf(&b, "%s=%s\n", e.key, e.raw)
+		}
+	}
+	return b.Bytes()
+}
+
+// Writes file atomically with permissions NetworkManager requires
+func (f *File) Write(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(FileMode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(f.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (g *Group) Keys() []string {
+	keys := []string{}
+	for _, e := range g.entries {
+		keys = append(keys, e.key)
+	}
+	return keys
+}
+
+// Returns unescaped value of the key
+func (g *Group) Get(key string) (string, bool) {
+	raw, ok := g.getRaw(key)
+	if !ok {
+		return "", false
+	}
+	return unescape(raw), true
+}
+
+// Returns list value of the key, items are separated by ';'
+func (g *Group) GetList(key string) ([]string, bool) {
+	raw, ok := g.getRaw(key)
+	if !ok {
+		return nil, false
+	}
+	items := []string{}
+	for _, item := range splitRawList(raw) {
+		items = append(items, unescape(item))
+	}
+	return items, true
+}
+
+func (g *Group) Set(key, value string) {
+	g.setRaw(key, escape(value))
+}
+
+// Sets list value, which is written with trailing ';' like NetworkManager does
+func (g *Group) SetList(key string, values []string) {
+	var b strings.Builder
+	for _, value := range values {
+		b.WriteString(strings.ReplaceAll(escape(value), ";", `\;`))
+		b.WriteString(";")
+	}
+	g.setRaw(key, b.String())
+}
+
+func (g *Group) Delete(key string) {
+	for i, e := range g.entries {
+		if e.key == key {
+			g.entries = append(g.entries[:i], g.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (g *Group) getRaw(key string) (string, bool) {
+	for _, e := range g.entries {
+		if e.key == key {
+			return e.raw, true
+		}
+	}
+	return "", false
+}
+
+func (g *Group) setRaw(key, raw string) {
+	for i, e := range g.entries {
+		if e.key == key {
+			g.entries[i].raw = raw
+			return
+		}
+	}
+	g.entries = append(g.entries, entry{key: key, raw: raw})
+}
+
+// Splits raw list on ';' which are not escaped, trailing ';' is optional
+func splitRawList(raw string) []string {
+	items := []string{}
+	var item strings.Builder
+	escaped := false
+	for _, r := range raw {
+		switch {
+		case escaped:
+			if r != ';' {
+				item.WriteRune('\\')
+			}
+			item.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			items = append(items, item.String())
+			item.Reset()
+		default:
+			item.WriteRune(r)
+		}
+	}
+	if item.Len() > 0 {
+		items = append(items, item.String())
+	}
+	return items
+}
+
+// Escapes value like GKeyFile, leading space is kept with \s
+func escape(value string) string {
+	var b strings.Builder
+	for i, r := range value {
+		switch {
+		case r == ' ' && i == 0:
+			b.WriteString(`\s`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func unescape(raw string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range raw {
+		if !escaped {
+			if r == '\\' {
+				escaped = true
+			} else {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		escaped = false
+		switch r {
+		case 's':
+			b.WriteRune(' ')
+		case 'n':
+			b.WriteRune('\n')
+		case 't':
+			b.WriteRune('\t')
+		case 'r':
+			b.WriteRune('\r')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+// Documentation for nftables:
+//
+// - https://wiki.nftables.org/wiki-nftables/index.php/Main_Page
+package nft
+
+import (
+	"fmt"
+	"strings"
+
+	l "github.com/charmbracelet/log"
+	"github.com/zarinit-routers/cli"
+)
+
+const NftExecutable = "nft"
+
+type Family = string
+
+const (
+	FamilyInet Family = "inet"
+	FamilyIP   Family = "ip"
+	FamilyIP6  Family = "ip6"
+)
+
+var log *l.Logger
+
+func init() {
+	log = l.WithPrefix("CLI nft")
+}
+
+// Loads ruleset in nft syntax atomically, either all of it is applied or nothing
+func ApplyRuleset(ruleset string) error {
+	log.Debug("Applying ruleset", "ruleset", ruleset)
+	output, err := cli.WithStdin([]byte(ruleset), NftExecutable, "-f", "-")
+	if err != nil {
+		return fmt.Errorf("failed apply ruleset: %s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func TableExists(family Family, name string) bool {
+	return cli.ExecuteErr(NftExecutable, "list", "table", family, name) == nil
+}
+
+// Deletes table with all its chains and rules, missing table is not an error
+func DeleteTable(family Family, name string) error {
+	if !TableExists(family, name) {
+		return nil
+	}
+	if err := cli.ExecuteErr(NftExecutable, "delete", "table", family, name); err != nil {
+		return fmt.Errorf("failed delete table %s %s: %s", family, name, err)
+	}
+	return nil
+}
+
+// Replaces table contents with body, creating the table if needed
+func ReplaceTable(family Family, name string, body string) error {
+	return ApplyRuleset(ReplaceTableRuleset(family, name, body))
+}
+
+// Returns ruleset used by [ReplaceTable], e.g. to load it later with nft -f
+func ReplaceTableRuleset(family Family, name string, body string) string {
+	// Declaring table before deleting it makes the ruleset valid when table is missing
+	return fmt.Sprintf("table %s %s {}\ndelete table %s %s\ntable %s %s {\n%s\n}\n",
+		family, name, family, name, family, name, body)
+}
@@ -0,0 +1,187 @@
+package nmcli
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/zarinit-routers/cli/iw"
+)
+
+var (
+	DefaultChannels2GHz = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	DefaultChannels5GHz = []int{36, 40, 44, 48, 52, 56, 60, 64, 100, 104, 108, 112, 116, 120, 124, 128, 132, 136, 140, 149, 153, 157, 161, 165}
+)
+
+// 20 MHz wide 2.4 GHz channels overlap with neighbours closer than this distance
+const channelOverlap2GHz = 5
+
+// Returns center frequency in MHz of the channel in specified band
+func ChannelFrequency(band WirelessBand, channel int) int {
+	if band == WirelessBand5GHz {
+		return 5000 + 5*channel
+	}
+	if channel == 14 {
+		return 2484
+	}
+	return 2407 + 5*channel
+}
+
+// Returns channel number of the center frequency in MHz, 0 if it is unknown
+func FrequencyChannel(frequency int) int {
+	switch {
+	case frequency == 2484:
+		return 14
+	case frequency >= 2412 && frequency < 2484:
+		return (frequency - 2407) / 5
+	case frequency >= 5955 && frequency <= 7115:
+		return (frequency - 5950) / 5
+	case frequency >= 5000 && frequency < 5955:
+		return (frequency - 5000) / 5
+	}
+	return 0
+}
+
+type ChannelScore struct {
+	Channel   int     `json:"channel"`
+	Frequency int     `json:"frequency"` // MHz
+	Score     float64 `json:"score"`     // Lower is better
+	Networks  int     `json:"networks"`  // Networks interfering with the channel
+}
+
+type AutoChannelOptions struct {
+	// Defaults to the band of the connection or to 2.4 GHz
+	Band WirelessBand
+	// Defaults to non-DFS channels allowed by regulatory domain of the device or,
+	// if it is unknown, to [DefaultChannels2GHz] or [DefaultChannels5GHz]
+	AllowedChannels []int
+	ScanOptions     WifiScanOptions
+}
+
+// Scans networks around device and returns allowed channels of the band ranked
+// from the least to the most congested one.
+func RecommendChannels(device string, opts AutoChannelOptions) ([]ChannelScore, error) {
+	if opts.Band == "" {
+		opts.Band = WirelessBand2GHz
+	}
+	if len(opts.AllowedChannels) == 0 {
+		opts.AllowedChannels = allowedChannels(device, opts.Band)
+	}
+
+	networks, err := ScanWifiWithOptions(device, opts.ScanOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed scan networks: %w", err)
+	}
+
+	return rankChannels(networks, opts.Band, opts.AllowedChannels), nil
+}
+
+func allowedChannels(device string, band WirelessBand) []int {
+	regBand := iw.Band2GHz
+	defaults := DefaultChannels2GHz
+	if band == WirelessBand5GHz {
+		regBand = iw.Band5GHz
+		defaults = DefaultChannels5GHz
+	}
+
+	phy, err := iw.GetPhy(device)
+	if err != nil {
+		log.Warn("Failed get phy of device, using default channels", "device", device, "error", err)
+		return defaults
+	}
+	domain, err := iw.GetRegulatoryDomain(phy)
+	if err != nil {
+		log.Warn("Failed get regulatory domain, using default channels", "phy", phy, "error", err)
+		return defaults
+	}
+	channels := iw.ChannelNumbers(domain.AllowedChannels(regBand, false))
+	if len(channels) == 0 {
+		return defaults
+	}
+	return channels
+}
+
+func rankChannels(networks []WifiNetwork, band WirelessBand, channels []int) []ChannelScore {
+	scores := []ChannelScore{}
+	for _, channel := range channels {
+		score := ChannelScore{
+			Channel:   channel,
+			Frequency: ChannelFrequency(band, channel),
+		}
+		for _, network := range networks {
+			// Channel numbers of other bands overlap, e.g. 6 GHz 149 isn't 5 GHz 149
+			if network.Band() != band {
+				continue
+			}
+			weight := channelInterference(band, channel, network.Channel)
+			if weight == 0 {
+				continue
+			}
+			score.Score += weight * float64(network.Signal)
+			score.Networks++
+		}
+		scores = append(scores, score)
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score < scores[j].Score
+		}
+		return scores[i].Channel < scores[j].Channel
+	})
+	return scores
+}
+
+// Returns how much a network on other channel interferes with channel, from 0 to 1
+func channelInterference(band WirelessBand, channel, other int) float64 {
+	if band == WirelessBand5GHz {
+		if channel == other {
+			return 1
+		}
+		return 0
+	}
+
+	distance := channel - other
+	if distance < 0 {
+		distance = -distance
+	}
+	if distance >= channelOverlap2GHz {
+		return 0
+	}
+	return float64(channelOverlap2GHz-distance) / channelOverlap2GHz
+}
+
+// Ranks channels for the access point of the connection. Scanning may be
+// unavailable while the device is running an access point.
+func (c *WirelessConnection) RecommendChannels(opts AutoChannelOptions) ([]ChannelScore, error) {
+	return RecommendChannels(c.Device, c.withBand(opts))
+}
+
+// Sets the least congested channel to the connection and returns it
+func (c *WirelessConnection) ApplyRecommendedChannel(opts AutoChannelOptions) (*ChannelScore, error) {
+	opts = c.withBand(opts)
+	scores, err := RecommendChannels(c.Device, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(scores) == 0 {
+		return nil, fmt.Errorf("no channels allowed for connection %q", c.Name)
+	}
+
+	best := scores[0]
+	// NetworkManager requires band to be set together with channel
+	if err := c.setOptions(OptionKeyWirelessBand, opts.Band, OptionKeyWirelessChanel, strconv.Itoa(best.Channel)); err != nil {
+		return nil, err
+	}
+	return &best, nil
+}
+
+func (c *WirelessConnection) withBand(opts AutoChannelOptions) AutoChannelOptions {
+	if opts.Band == "" {
+		opts.Band = c.GetBand()
+	}
+	if opts.Band == "" {
+		opts.Band = WirelessBand2GHz
+	}
+	return opts
+}
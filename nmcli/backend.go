@@ -0,0 +1,143 @@
+package nmcli
+
+import (
+	"strings"
+	"time"
+)
+
+// Way of talking to NetworkManager. Options of connections and devices are
+// always exposed in nmcli format, whatever backend produced them.
+type backend interface {
+	listConnections() ([]Connection, error)
+	showConnection(name string) (*Connection, error)
+	// params are nmcli `connection add` arguments
+	addConnection(t ConnectionType, deviceName, connectionName string, params []string) error
+	// params are nmcli `connection modify` arguments
+	modifyConnection(c *Connection, params []string) error
+	activateConnection(c *Connection, timeout time.Duration) error
+	deactivateConnection(c *Connection) error
+	deleteConnection(c *Connection) error
+
+	listDevices() ([]string, error)
+	showDevice(name string) (*Device, error)
+	setDeviceManaged(name string, managed bool) error
+	// Applies changed options of the active connection without reactivation
+	reapplyDevice(name string) error
+	// Requests scan and waits until it completes
+	requestWifiScan(deviceName string) error
+	// Empty device name lists networks seen by all devices
+	listWifiNetworks(deviceName string) ([]WifiNetwork, error)
+	// Version of NetworkManager in any format [ParseVersion] understands
+	version() (string, error)
+}
+
+var currentBackend backend = &cliBackend{}
+
+// Use nmcli executable to talk to NetworkManager. This is the default backend.
+func UseCLIBackend() {
+	currentBackend = &cliBackend{}
+	forgetVersion()
+}
+
+type cliBackend struct{}
+
+func (b *cliBackend) listConnections() ([]Connection, error) {
+	output, err := runNmcli(terseFlag, "connection")
+	if err != nil {
+		return nil, err
+	}
+	return parseConnections(output), nil
+}
+
+func (b *cliBackend) showConnection(name string) (*Connection, error) {
+	output, err := runNmcli(allFieldsFlag, terseFlag, showSecretsFlag, "connection", "show", name)
+	if err != nil {
+		return nil, err
+	}
+	return parseShowConnectionOutput(output), nil
+}
+
+func (b *cliBackend) addConnection(t ConnectionType, deviceName, connectionName string, params []string) error {
+	args := []string{"connection", "add", "type", string(t), "ifname", deviceName, "con-name", connectionName}
+	args = append(args, params...)
+	return runNmcliErr(args...)
+}
+
+func (b *cliBackend) modifyConnection(c *Connection, params []string) error {
+	args := append([]string{"connection", "modify", "uuid", c.UUID}, params...)
+	return runNmcliErr(args...)
+}
+
+func (b *cliBackend) activateConnection(c *Connection, timeout time.Duration) error {
+	args := []string{"connection", "up", "uuid", c.UUID}
+	if timeout > 0 {
+		args = append([]string{waitFlag(timeout)}, args...)
+	}
+	return runNmcliErr(args...)
+}
+
+func (b *cliBackend) deactivateConnection(c *Connection) error {
+	return runNmcliErr("connection", "down", "uuid", c.UUID)
+}
+
+func (b *cliBackend) deleteConnection(c *Connection) error {
+	return runNmcliErr("connection", "delete", "uuid", c.UUID)
+}
+
+func (b *cliBackend) listDevices() ([]string, error) {
+	output, err := runNmcli(terseFlag, getFieldsFlag("DEVICE"), "device")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
+
+func (b *cliBackend) showDevice(name string) (*Device, error) {
+	data, err := runNmcli(showSecretsFlag, terseFlag, allFieldsFlag, "device", "show", name)
+	if err != nil {
+		return nil, err
+	}
+	return &Device{keyValOutput: newKeyValOutput(data)}, nil
+}
+
+func (b *cliBackend) setDeviceManaged(name string, managed bool) error {
+	value := "no"
+	if managed {
+		value = TrueValue
+	}
+	return runNmcliErr("device", "set", name, "managed", value)
+}
+
+func (b *cliBackend) reapplyDevice(name string) error {
+	return runNmcliErr("device", "reapply", name)
+}
+
+// "device wifi rescan" returns before the scan completes, listing with rescan
+// waits for it
+func (b *cliBackend) requestWifiScan(deviceName string) error {
+	return runNmcliErr(terseFlag, getFieldsFlag(WifiListFieldBSSID), "device", "wifi", "list", "--rescan", "yes", "ifname", deviceName)
+}
+
+func (b *cliBackend) listWifiNetworks(deviceName string) ([]WifiNetwork, error) {
+	args := []string{terseFlag, getFieldsFlag(wifiListFields...), "device", "wifi", "list", "--rescan", "no"}
+	if deviceName != "" {
+		args = append(args, "ifname", deviceName)
+	}
+	output, err := runNmcli(args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseWifiList(output), nil
+}
+
+func (b *cliBackend) version() (string, error) {
+	output, err := runNmcli(getFieldsFlag("VERSION"), "general")
+	if err == nil {
+		return string(output), nil
+	}
+	// VERSION field is missing in old releases, nmcli is usually the same
+	// version as the daemon then
+	log.Debug("Failed get NetworkManager version, using nmcli one", "error", err)
+	output, err = runNmcli("--version")
+	return string(output), err
+}
@@ -0,0 +1,93 @@
+package nmcli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+type CheckpointFlags uint32
+
+const (
+	CheckpointFlagNone CheckpointFlags = 0
+	// Destroy all existing checkpoints before creating the new one
+	CheckpointFlagDestroyAll CheckpointFlags = 0x1
+	// Delete connections added after the checkpoint on rollback
+	CheckpointFlagDeleteNewConnections CheckpointFlags = 0x2
+	// Disconnect devices added after the checkpoint on rollback
+	CheckpointFlagDisconnectNewDevices CheckpointFlags = 0x4
+)
+
+type CheckpointRollbackResult uint32
+
+const (
+	CheckpointRollbackSucceeded       CheckpointRollbackResult = 0
+	CheckpointRollbackNoDevice        CheckpointRollbackResult = 1
+	CheckpointRollbackDeviceUnmanaged CheckpointRollbackResult = 2
+	CheckpointRollbackFailed          CheckpointRollbackResult = 3
+)
+
+// Snapshot of devices configuration which NetworkManager restores
+// automatically when rollback timeout expires, unless it is destroyed first.
+// Checkpoints are only available with D-Bus backend.
+type Checkpoint struct {
+	path    dbus.ObjectPath
+	backend *dbusBackend
+}
+
+// Creates checkpoint of specified devices, all devices are included if none
+// specified. Zero rollback timeout disables automatic rollback.
+func CreateCheckpoint(devices []string, rollbackTimeout time.Duration, flags CheckpointFlags) (*Checkpoint, error) {
+	b, ok := currentBackend.(*dbusBackend)
+	if !ok {
+		return nil, fmt.Errorf("checkpoints require D-Bus backend")
+	}
+
+	paths := []dbus.ObjectPath{}
+	for _, device := range devices {
+		path, err := b.devicePath(device)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	var path dbus.ObjectPath
+	err := b.object(dbusPath).Call(dbusInterface+".CheckpointCreate", 0,
+		paths, uint32(rollbackTimeout.Seconds()), uint32(flags)).Store(&path)
+	if err != nil {
+		return nil, fmt.Errorf("failed create checkpoint: %s", err)
+	}
+	return &Checkpoint{path: path, backend: b}, nil
+}
+
+// Restores devices to the checkpoint state, returns result for each device
+func (c *Checkpoint) Rollback() (map[string]CheckpointRollbackResult, error) {
+	results := map[string]uint32{}
+	err := c.backend.object(dbusPath).Call(dbusInterface+".CheckpointRollback", 0, c.path).Store(&results)
+	if err != nil {
+		return nil, fmt.Errorf("failed rollback checkpoint: %s", err)
+	}
+
+	devices := map[string]CheckpointRollbackResult{}
+	for path, result := range results {
+		name := c.backend.deviceInterface(dbus.ObjectPath(path))
+		if name == "" {
+			name = path
+		}
+		devices[name] = CheckpointRollbackResult(result)
+	}
+	return devices, nil
+}
+
+// Keeps current configuration and drops the checkpoint
+func (c *Checkpoint) Destroy() error {
+	return c.backend.object(dbusPath).Call(dbusInterface+".CheckpointDestroy", 0, c.path).Err
+}
+
+// Resets rollback timer so that rollback happens after timeout from now
+func (c *Checkpoint) AdjustRollbackTimeout(timeout time.Duration) error {
+	return c.backend.object(dbusPath).Call(dbusInterface+".CheckpointAdjustRollbackTimeout", 0,
+		c.path, uint32(timeout.Seconds())).Err
+}
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"net"
 	"strings"
-
-	"github.com/zarinit-routers/cli"
 )
 
 type Connection struct {
@@ -26,16 +24,11 @@ const (
 	ConnectionTypeWIFI     ConnectionType = "wifi"
 	ConnectionTypeWireless ConnectionType = "802-11-wireless" // Access point connection
 	ConnectionTypeEthernet ConnectionType = "ethernet"
+	ConnectionTypeBridge   ConnectionType = "bridge"
 )
 
 func GetConnections() ([]Connection, error) {
-	output, err := cli.Execute("nmcli", terseFlag, "connection")
-	if err != nil {
-		return nil, err
-	}
-
-	connections := parseConnections(output)
-	return connections, nil
+	return currentBackend.listConnections()
 }
 
 func parseConnections(cliOutput []byte) []Connection {
@@ -76,9 +69,10 @@ func createConnection(
 	deviceName string,
 	connectionName string, additionalCliParams []string) (*Connection, error) {
 
-	params := []string{"connection", "add", "type", string(t), "ifname", deviceName, "con-name", connectionName}
-	params = append(params, additionalCliParams...)
-	err := cli.ExecuteErr("nmcli", params...)
+	if err := requireParamCapabilities(additionalCliParams); err != nil {
+		return nil, err
+	}
+	err := currentBackend.addConnection(t, deviceName, connectionName, additionalCliParams)
 	if err != nil {
 		return nil, err
 	}
@@ -86,48 +80,74 @@ func createConnection(
 }
 
 const (
-	OptionKeyAutoconnect   = "connection.autoconnect"
-	OptionKeyIP4Method     = "ipv4.method"
-	OptionKeyIP4Addresses  = "ipv4.addresses"
-	OptionKeyGeneralState  = "GENERAL.STATE"
-	OptionKeyDNSAddresses  = "ipv4.dns"
-	OptionKeyDHCPRange     = "ipv4.dhcp-range"
-	OptionKeyDHCPLeaseTime = "ipv4.dhcp-lease-time"
-	OptionKeyIP4Gateway    = "ipv4.gateway"
+	OptionKeyAutoconnect      = "connection.autoconnect"
+	OptionKeyInterfaceName    = "connection.interface-name"
+	OptionKeyIP4Method        = "ipv4.method"
+	OptionKeyIP4Addresses     = "ipv4.addresses"
+	OptionKeyGeneralState     = "GENERAL.STATE"
+	OptionKeyDNSAddresses     = "ipv4.dns"
+	OptionKeyIP4IgnoreAutoDNS = "ipv4.ignore-auto-dns"
+	OptionKeyDHCPRange        = "ipv4.dhcp-range"
+	OptionKeyDHCPLeaseTime    = "ipv4.dhcp-lease-time"
+	OptionKeySharedDHCPRange  = "ipv4.shared-dhcp-range"
+	OptionKeySharedDHCPLease  = "ipv4.shared-dhcp-lease-time"
+	OptionKeyIP4Gateway       = "ipv4.gateway"
+	OptionKeyMaster           = "connection.master"
+	OptionKeySlaveType        = "connection.slave-type"
 )
 
 type IP4Method = string
 
 const (
 	ConnectionIP4MethodShared IP4Method = "shared"
+	ConnectionIP4MethodAuto   IP4Method = "auto"
+	ConnectionIP4MethodManual IP4Method = "manual"
 )
 
 func (c *Connection) SetIP4Method(method IP4Method) error {
 	return c.setOption(OptionKeyIP4Method, string(method))
 }
+func (c *Connection) GetIP4Method() IP4Method {
+	return c.getOption(OptionKeyIP4Method)
+}
 func (c *Connection) SetIP4Address(address string) error {
 	return c.setOption(OptionKeyIP4Addresses, address)
 }
 
 func (c *Connection) Up() error {
-	return cli.ExecuteErr("nmcli", "connection", "up", c.Name)
+	return currentBackend.activateConnection(c, 0)
 }
 func (c *Connection) Down() error {
-	return cli.ExecuteErr("nmcli", "connection", "down", c.Name)
+	return currentBackend.deactivateConnection(c)
+}
+func (c *Connection) Delete() error {
+	if err := currentBackend.deleteConnection(c); err != nil {
+		return err
+	}
+	if err := c.removeDHCPDropIn(); err != nil {
+		return err
+	}
+	if err := c.removeDHCPReservations(); err != nil {
+		return err
+	}
+	if c.Type == ConnectionTypeWIFI || c.Type == ConnectionTypeWireless {
+		return (&WirelessConnection{c}).ClearTxPower()
+	}
+	return nil
 }
 
-// TODO: move to net.IP
+// Deprecated: Use [Connection.SetDNSServers], which also handles IPv6 servers
 func (c *Connection) SetDNSAddresses(addresses []string) error {
 	return c.setOption(OptionKeyDNSAddresses, strings.Join(addresses, ","))
 }
 
-// Deprecated: THis method must not be used
+// Deprecated: ipv4.dhcp-range doesn't exist, use [Connection.SetDHCPServer]
 func (c *Connection) SetDHCPRange(from, to net.IP) error {
 	return c.setOption(OptionKeyDHCPRange, strings.Join(
 		[]string{from.String(), to.String()}, ","))
 }
 
-// Deprecated: THis method must not be used
+// Deprecated: ipv4.dhcp-lease-time doesn't exist, use [Connection.SetDHCPServer]
 func (c *Connection) SetDHCPLeaseTime(secs int) error {
 	return c.setOption(OptionKeyDHCPLeaseTime, fmt.Sprintf("%d", secs))
 }
@@ -155,23 +175,62 @@ func (c *Connection) IsActive() bool {
 	return ConnectionState(state) == ConnectionStateActivated
 }
 
+// Returns device the connection is active on, falls back to the interface
+// name of the profile
+func (c *Connection) GetActiveDevice() string {
+	devices := splitList(c.getOption("GENERAL.DEVICES"))
+	if len(devices) > 0 {
+		return devices[0]
+	}
+	return c.Device
+}
+
 func (c *Connection) setOption(optionName, optionValue string) error {
 	log.Debug("Setting option", "option", optionName, "newValue", optionValue, "currentValue", c.options[optionName])
-	err := cli.ExecuteErr("nmcli", "connection", "modify", c.Name, optionName, optionValue)
+	if err := requireParamCapabilities([]string{optionName, optionValue}); err != nil {
+		return err
+	}
+	err := currentBackend.modifyConnection(c, []string{optionName, optionValue})
 	if err != nil {
-		return fmt.Errorf("failed set option %q to %q: %s", optionName, optionValue, err)
+		return fmt.Errorf("failed set option %q to %q: %w", optionName, optionValue, err)
 	}
 
 	c.options[optionName] = optionValue
 	return nil
 }
 
-func GetConnection(name string) (*Connection, error) {
-	output, err := cli.Execute("nmcli", allFieldsFlag, terseFlag, showSecretsFlag, "connection", "show", fmt.Sprintf("%q", name))
-	if err != nil {
-		return nil, fmt.Errorf("failed execute NetworkManger: %s", err)
+// Sets several options at once, so NetworkManager validates them together.
+// params are option name and value pairs, "remove" followed by setting name
+// removes the whole setting.
+func (c *Connection) setOptions(params ...string) error {
+	log.Debug("Setting options", "connection", c.Name, "options", params)
+	if err := requireParamCapabilities(params); err != nil {
+		return err
+	}
+	if err := currentBackend.modifyConnection(c, params); err != nil {
+		return fmt.Errorf("failed set options of connection %q: %w", c.Name, err)
 	}
-	return parseShowConnectionOutput(output), nil
+
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i] == removeSettingParam {
+			prefix := params[i+1] + "."
+			for option := range c.options {
+				if strings.HasPrefix(option, prefix) {
+					delete(c.options, option)
+				}
+			}
+			continue
+		}
+		c.options[params[i]] = params[i+1]
+	}
+	return nil
+}
+
+const removeSettingParam = "remove"
+
+// Looks up connection by name or UUID, missing one is [ErrConnectionNotFound]
+func GetConnection(name string) (*Connection, error) {
+	return currentBackend.showConnection(name)
 }
 
 func parseShowConnectionOutput(output []byte) *Connection {
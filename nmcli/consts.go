@@ -3,6 +3,7 @@ package nmcli
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 const (
@@ -16,3 +17,7 @@ const (
 func getFieldsFlag(fields ...string) string {
 	return fmt.Sprintf("--get-values=%s", strings.Join(fields, ","))
 }
+
+func waitFlag(timeout time.Duration) string {
+	return fmt.Sprintf("--wait=%d", int(timeout.Seconds()))
+}
@@ -0,0 +1,1288 @@
+// Documentation for NetworkManager D-Bus API:
+//
+// - https://www.networkmanager.dev/docs/api/latest/spec.html
+package nmcli
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	dbusDestination  = "org.freedesktop.NetworkManager"
+	dbusPath         = dbus.ObjectPath("/org/freedesktop/NetworkManager")
+	dbusSettingsPath = dbus.ObjectPath("/org/freedesktop/NetworkManager/Settings")
+
+	dbusInterface                 = "org.freedesktop.NetworkManager"
+	dbusInterfaceSettings         = "org.freedesktop.NetworkManager.Settings"
+	dbusInterfaceSettingsConn     = "org.freedesktop.NetworkManager.Settings.Connection"
+	dbusInterfaceActiveConnection = "org.freedesktop.NetworkManager.Connection.Active"
+	dbusInterfaceDevice           = "org.freedesktop.NetworkManager.Device"
+	dbusInterfaceWireless         = "org.freedesktop.NetworkManager.Device.Wireless"
+	dbusInterfaceAccessPoint      = "org.freedesktop.NetworkManager.AccessPoint"
+	dbusInterfaceIP4Config        = "org.freedesktop.NetworkManager.IP4Config"
+	dbusInterfaceIP6Config        = "org.freedesktop.NetworkManager.IP6Config"
+	dbusInterfaceProperties       = "org.freedesktop.DBus.Properties"
+
+	dbusNoObject = dbus.ObjectPath("/")
+)
+
+// Same default as nmcli uses for --wait
+const dbusDefaultActivationTimeout = 90 * time.Second
+
+// Interval of polling active connection state during activation
+const dbusActivationPollInterval = 500 * time.Millisecond
+
+// Same as nmcli waits for "device wifi list --rescan yes"
+const dbusScanTimeout = 15 * time.Second
+
+type dbusSettings = map[string]map[string]dbus.Variant
+
+// Talk to NetworkManager over D-Bus using conn. System bus is used if conn is
+// nil, private bus connection may be passed to talk to a fake service.
+func UseDBusBackend(conn *dbus.Conn) error {
+	if conn == nil {
+		var err error
+		conn, err = dbus.SystemBus()
+		if err != nil {
+			return fmt.Errorf("failed connect to system bus: %s", err)
+		}
+	}
+
+	b := &dbusBackend{conn: conn}
+	version, err := b.object(dbusPath).GetProperty(dbusInterface + ".Version")
+	if err != nil {
+		return fmt.Errorf("%w on D-Bus: %s", ErrNMNotRunning, err)
+	}
+	log.Debug("Using D-Bus backend", "networkManagerVersion", version.Value())
+
+	currentBackend = b
+	forgetVersion()
+	return nil
+}
+
+type dbusBackend struct {
+	conn *dbus.Conn
+}
+
+func (b *dbusBackend) object(path dbus.ObjectPath) dbus.BusObject {
+	return b.conn.Object(dbusDestination, path)
+}
+
+func (b *dbusBackend) properties(path dbus.ObjectPath, iface string) (map[string]dbus.Variant, error) {
+	props := map[string]dbus.Variant{}
+	err := b.object(path).Call(dbusInterfaceProperties+".GetAll", 0, iface).Store(&props)
+	return props, err
+}
+
+func (b *dbusBackend) settingsPaths() ([]dbus.ObjectPath, error) {
+	paths := []dbus.ObjectPath{}
+	err := b.object(dbusSettingsPath).Call(dbusInterfaceSettings+".ListConnections", 0).Store(&paths)
+	return paths, err
+}
+
+func (b *dbusBackend) settings(path dbus.ObjectPath) (dbusSettings, error) {
+	settings := dbusSettings{}
+	err := b.object(path).Call(dbusInterfaceSettingsConn+".GetSettings", 0).Store(&settings)
+	return settings, err
+}
+
+// Settings of the connection including secrets of security settings
+func (b *dbusBackend) settingsWithSecrets(path dbus.ObjectPath) (dbusSettings, error) {
+	settings, err := b.settings(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"802-11-wireless-security", "802-1x"} {
+		if _, ok := settings[name]; !ok {
+			continue
+		}
+		secrets := dbusSettings{}
+		if err := b.object(path).Call(dbusInterfaceSettingsConn+".GetSecrets", 0, name).Store(&secrets); err != nil {
+			log.Debug("Failed get connection secrets", "setting", name, "error", err)
+			continue
+		}
+		for key, value := range secrets[name] {
+			settings[name][key] = value
+		}
+	}
+	return settings, nil
+}
+
+func (b *dbusBackend) settingsPathByUUID(uuid string) (dbus.ObjectPath, error) {
+	var path dbus.ObjectPath
+	err := b.object(dbusSettingsPath).Call(dbusInterfaceSettings+".GetConnectionByUuid", 0, uuid).Store(&path)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %s", ErrConnectionNotFound, uuid, err)
+	}
+	return path, nil
+}
+
+type dbusActiveConnection struct {
+	path    dbus.ObjectPath
+	state   uint32
+	devices []dbus.ObjectPath
+}
+
+// Active connections keyed by connection UUID
+func (b *dbusBackend) activeConnections() (map[string]dbusActiveConnection, error) {
+	variant, err := b.object(dbusPath).GetProperty(dbusInterface + ".ActiveConnections")
+	if err != nil {
+		return nil, err
+	}
+	paths, _ := variant.Value().([]dbus.ObjectPath)
+
+	active := map[string]dbusActiveConnection{}
+	for _, path := range paths {
+		props, err := b.properties(path, dbusInterfaceActiveConnection)
+		if err != nil {
+			log.Debug("Failed get active connection", "path", path, "error", err)
+			continue
+		}
+		uuid, _ := props["Uuid"].Value().(string)
+		state, _ := props["State"].Value().(uint32)
+		devices, _ := props["Devices"].Value().([]dbus.ObjectPath)
+		active[uuid] = dbusActiveConnection{path: path, state: state, devices: devices}
+	}
+	return active, nil
+}
+
+func (b *dbusBackend) deviceInterface(path dbus.ObjectPath) string {
+	variant, err := b.object(path).GetProperty(dbusInterfaceDevice + ".Interface")
+	if err != nil {
+		return ""
+	}
+	iface, _ := variant.Value().(string)
+	return iface
+}
+
+func (b *dbusBackend) listConnections() ([]Connection, error) {
+	paths, err := b.settingsPaths()
+	if err != nil {
+		return nil, err
+	}
+	active, err := b.activeConnections()
+	if err != nil {
+		return nil, err
+	}
+
+	connections := []Connection{}
+	for _, path := range paths {
+		settings, err := b.settings(path)
+		if err != nil {
+			log.Warnf("Bad connection %s: %s", path, err)
+			continue
+		}
+		options := settingsToOptions(settings)
+		conn := Connection{
+			Name: options["connection.id"],
+			UUID: options["connection.uuid"],
+			Type: ConnectionType(options["connection.type"]),
+		}
+		if ac, ok := active[conn.UUID]; ok && len(ac.devices) > 0 {
+			conn.Device = b.deviceInterface(ac.devices[0])
+		}
+		connections = append(connections, conn)
+	}
+	return connections, nil
+}
+
+// Looks up connection by id or UUID like `nmcli connection show` does
+func (b *dbusBackend) findConnection(name string) (dbus.ObjectPath, error) {
+	paths, err := b.settingsPaths()
+	if err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		settings, err := b.settings(path)
+		if err != nil {
+			continue
+		}
+		id, _ := settings["connection"]["id"].Value().(string)
+		uuid, _ := settings["connection"]["uuid"].Value().(string)
+		if id == name || uuid == name {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q", ErrConnectionNotFound, name)
+}
+
+func (b *dbusBackend) showConnection(name string) (*Connection, error) {
+	path, err := b.findConnection(name)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := b.settingsWithSecrets(path)
+	if err != nil {
+		return nil, err
+	}
+
+	options := settingsToOptions(settings)
+	active, err := b.activeConnections()
+	if err != nil {
+		return nil, err
+	}
+	if ac, ok := active[options["connection.uuid"]]; ok {
+		options[OptionKeyGeneralState] = activeConnectionStateNames[ac.state]
+		devices := []string{}
+		for _, device := range ac.devices {
+			devices = append(devices, b.deviceInterface(device))
+		}
+		options["GENERAL.DEVICES"] = strings.Join(devices, ",")
+	}
+
+	return &Connection{
+		keyValOutput: &keyValOutput{options: options},
+
+		Name:   options["connection.id"],
+		UUID:   options["connection.uuid"],
+		Type:   ConnectionType(options["connection.type"]),
+		Device: options["connection.interface-name"],
+	}, nil
+}
+
+func (b *dbusBackend) addConnection(t ConnectionType, deviceName, connectionName string, params []string) error {
+	if len(params)%2 != 0 {
+		return fmt.Errorf("odd number of connection parameters")
+	}
+	uuid, err := newUUID()
+	if err != nil {
+		return err
+	}
+
+	settings := dbusSettings{}
+	pairs := []string{
+		"connection.type", connectionTypeAliases.resolve(string(t)),
+		"connection.interface-name", deviceName,
+		"connection.id", connectionName,
+		"connection.uuid", uuid,
+	}
+	for _, pair := range [][]string{pairs, params} {
+		for i := 0; i < len(pair); i += 2 {
+			if err := setSettingsOption(settings, optionKeyAliases.resolve(pair[i]), pair[i+1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	var path dbus.ObjectPath
+	return b.object(dbusSettingsPath).Call(dbusInterfaceSettings+".AddConnection", 0, settings).Store(&path)
+}
+
+func (b *dbusBackend) modifyConnection(c *Connection, params []string) error {
+	if len(params)%2 != 0 {
+		return fmt.Errorf("odd number of connection parameters")
+	}
+	path, err := b.settingsPathByUUID(c.UUID)
+	if err != nil {
+		return err
+	}
+	// Update replaces all settings, so secrets must be passed back too
+	settings, err := b.settingsWithSecrets(path)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(params); i += 2 {
+		if params[i] == removeSettingParam {
+			delete(settings, optionKeyAliases.resolve(params[i+1]))
+			continue
+		}
+		if err := setSettingsOption(settings, optionKeyAliases.resolve(params[i]), params[i+1]); err != nil {
+			return err
+		}
+	}
+	return b.object(path).Call(dbusInterfaceSettingsConn+".Update", 0, settings).Err
+}
+
+func (b *dbusBackend) activateConnection(c *Connection, timeout time.Duration) error {
+	if timeout == 0 {
+		timeout = dbusDefaultActivationTimeout
+	}
+	path, err := b.settingsPathByUUID(c.UUID)
+	if err != nil {
+		return err
+	}
+
+	var activePath dbus.ObjectPath
+	if err := b.object(dbusPath).Call(dbusInterface+".ActivateConnection", 0, path, dbusNoObject, dbusNoObject).Store(&activePath); err != nil {
+		return err
+	}
+
+	devices := []dbus.ObjectPath{}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		props, err := b.properties(activePath, dbusInterfaceActiveConnection)
+		if err != nil {
+			// Active connection object is removed when activation fails
+			return b.activationFailure(devices)
+		}
+		if d, ok := props["Devices"].Value().([]dbus.ObjectPath); ok && len(d) > 0 {
+			devices = d
+		}
+		switch state, _ := props["State"].Value().(uint32); state {
+		case activeConnectionStateActivated:
+			return nil
+		case activeConnectionStateDeactivating, activeConnectionStateDeactivated:
+			return b.activationFailure(devices)
+		}
+		time.Sleep(dbusActivationPollInterval)
+	}
+	return fmt.Errorf("%w while activating connection %q", ErrTimeout, c.Name)
+}
+
+const (
+	activeConnectionStateActivating   = 1
+	activeConnectionStateActivated    = 2
+	activeConnectionStateDeactivating = 3
+	activeConnectionStateDeactivated  = 4
+)
+
+var activeConnectionStateNames = map[uint32]string{
+	activeConnectionStateActivating:   "activating",
+	activeConnectionStateActivated:    ConnectionStateActivated,
+	activeConnectionStateDeactivating: "deactivating",
+	activeConnectionStateDeactivated:  "deactivated",
+}
+
+// Messages match the ones printed by nmcli, so errors can be classified the same way
+var deviceStateReasonMessages = map[uint32]string{
+	7:  "secrets were required, but not provided",
+	8:  "802.1x supplicant disconnected",
+	9:  "802.1x supplicant configuration failed",
+	10: "802.1x supplicant failed",
+	11: "802.1x supplicant took too long to authenticate",
+	53: "ssid not found",
+}
+
+func (b *dbusBackend) activationFailure(devices []dbus.ObjectPath) error {
+	for _, device := range devices {
+		variant, err := b.object(device).GetProperty(dbusInterfaceDevice + ".StateReason")
+		if err != nil {
+			continue
+		}
+		reason, ok := variant.Value().([]any)
+		if !ok || len(reason) < 2 {
+			continue
+		}
+		code, _ := reason[1].(uint32)
+		if message, ok := deviceStateReasonMessages[code]; ok {
+			return fmt.Errorf("%w: %s", ErrActivationFailed, message)
+		}
+		return fmt.Errorf("%w: device state reason %d", ErrActivationFailed, code)
+	}
+	return ErrActivationFailed
+}
+
+func (b *dbusBackend) deactivateConnection(c *Connection) error {
+	active, err := b.activeConnections()
+	if err != nil {
+		return err
+	}
+	ac, ok := active[c.UUID]
+	if !ok {
+		return fmt.Errorf("connection %q is not active", c.Name)
+	}
+	return b.object(dbusPath).Call(dbusInterface+".DeactivateConnection", 0, ac.path).Err
+}
+
+func (b *dbusBackend) deleteConnection(c *Connection) error {
+	path, err := b.settingsPathByUUID(c.UUID)
+	if err != nil {
+		return err
+	}
+	return b.object(path).Call(dbusInterfaceSettingsConn+".Delete", 0).Err
+}
+
+func (b *dbusBackend) devicePath(name string) (dbus.ObjectPath, error) {
+	var path dbus.ObjectPath
+	err := b.object(dbusPath).Call(dbusInterface+".GetDeviceByIpIface", 0, name).Store(&path)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %s", ErrDeviceNotFound, name, err)
+	}
+	return path, nil
+}
+
+const (
+	deviceTypeEthernet = 1
+	deviceTypeWifi     = 2
+
+	wifiDeviceCapabilityAP = 0x40
+)
+
+var deviceTypeNames = map[uint32]string{
+	deviceTypeEthernet: "ethernet",
+	deviceTypeWifi:     "wifi",
+	13:                 "bridge",
+	14:                 "generic",
+	8:                  "gsm",
+	32:                 "loopback",
+}
+
+var deviceStateNames = map[uint32]string{
+	10:  "unmanaged",
+	20:  "unavailable",
+	30:  "disconnected",
+	40:  "connecting (prepare)",
+	50:  "connecting (configuring)",
+	60:  "connecting (need authentication)",
+	70:  "connecting (getting IP configuration)",
+	80:  "connecting (checking IP connectivity)",
+	90:  "connecting (starting secondary connections)",
+	100: "connected",
+	110: "deactivating",
+	120: "connection failed",
+}
+
+func (b *dbusBackend) listDevices() ([]string, error) {
+	paths := []dbus.ObjectPath{}
+	if err := b.object(dbusPath).Call(dbusInterface+".GetDevices", 0).Store(&paths); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, path := range paths {
+		if name := b.deviceInterface(path); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (b *dbusBackend) showDevice(name string) (*Device, error) {
+	path, err := b.devicePath(name)
+	if err != nil {
+		return nil, err
+	}
+	props, err := b.properties(path, dbusInterfaceDevice)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceType, _ := props["DeviceType"].Value().(uint32)
+	state, _ := props["State"].Value().(uint32)
+	options := map[string]string{
+		"GENERAL.DEVICE":   name,
+		"GENERAL.TYPE":     deviceTypeNames[deviceType],
+		"GENERAL.STATE":    fmt.Sprintf("%d (%s)", state, deviceStateNames[state]),
+		"GENERAL.DRIVER":   variantToOption(props["Driver"]),
+		"GENERAL.HWADDR":   variantToOption(props["HwAddress"]),
+		"GENERAL.MTU":      variantToOption(props["Mtu"]),
+		"GENERAL.UDI":      variantToOption(props["Udi"]),
+		"GENERAL.IP-IFACE": variantToOption(props["IpInterface"]),
+	}
+	if connectivity, ok := props["Ip4Connectivity"].Value().(uint32); ok && int(connectivity) < len(connectivityNames) {
+		options[OptionKeyIP4Connectivity] = fmt.Sprintf("%d (%s)", connectivity, connectivityNames[connectivity])
+	}
+	if acPath, ok := props["ActiveConnection"].Value().(dbus.ObjectPath); ok && acPath != dbusNoObject {
+		if id, err := b.object(acPath).GetProperty(dbusInterfaceActiveConnection + ".Id"); err == nil {
+			options["GENERAL.CONNECTION"] = variantToOption(id)
+		}
+	}
+
+	if deviceType == deviceTypeWifi {
+		wireless, err := b.properties(path, dbusInterfaceWireless)
+		if err != nil {
+			return nil, err
+		}
+		capabilities, _ := wireless["WirelessCapabilities"].Value().(uint32)
+		options[OptionKeyCanBeAccessPoint] = formatBool(capabilities&wifiDeviceCapabilityAP != 0)
+		if options["GENERAL.HWADDR"] == "" {
+			options["GENERAL.HWADDR"] = variantToOption(wireless["HwAddress"])
+		}
+	}
+
+	if ip4Path, ok := props["Ip4Config"].Value().(dbus.ObjectPath); ok && ip4Path != dbusNoObject {
+		if ip4, err := b.properties(ip4Path, dbusInterfaceIP4Config); err == nil {
+			for i, address := range addressDataToStrings(ip4["AddressData"]) {
+				options[fmt.Sprintf("IP4.ADDRESS[%d]", i+1)] = address
+			}
+			options["IP4.GATEWAY"] = variantToOption(ip4["Gateway"])
+			nameservers, _ := ip4["NameserverData"].Value().([]map[string]dbus.Variant)
+			for i, nameserver := range nameservers {
+				options[fmt.Sprintf("IP4.DNS[%d]", i+1)] = variantToOption(nameserver["address"])
+			}
+			setIndexedOptions(options, "IP4.DOMAIN", ip4["Domains"], ip4["Searches"])
+		}
+	}
+	if ip6Path, ok := props["Ip6Config"].Value().(dbus.ObjectPath); ok && ip6Path != dbusNoObject {
+		if ip6, err := b.properties(ip6Path, dbusInterfaceIP6Config); err == nil {
+			for i, address := range addressDataToStrings(ip6["AddressData"]) {
+				options[fmt.Sprintf("IP6.ADDRESS[%d]", i+1)] = address
+			}
+			options["IP6.GATEWAY"] = variantToOption(ip6["Gateway"])
+			nameservers, _ := ip6["Nameservers"].Value().([][]byte)
+			for i, nameserver := range nameservers {
+				options[fmt.Sprintf("IP6.DNS[%d]", i+1)] = net.IP(nameserver).String()
+			}
+			setIndexedOptions(options, "IP6.DOMAIN", ip6["Domains"], ip6["Searches"])
+		}
+	}
+
+	return &Device{keyValOutput: &keyValOutput{options: options}}, nil
+}
+
+// Sets string lists as options numbered like nmcli does, e.g. IP4.DOMAIN[1]
+func setIndexedOptions(options map[string]string, name string, lists ...dbus.Variant) {
+	i := 1
+	for _, list := range lists {
+		values, _ := list.Value().([]string)
+		for _, value := range values {
+			options[fmt.Sprintf("%s[%d]", name, i)] = value
+			i++
+		}
+	}
+}
+
+func (b *dbusBackend) setDeviceManaged(name string, managed bool) error {
+	path, err := b.devicePath(name)
+	if err != nil {
+		return err
+	}
+	return b.object(path).SetProperty(dbusInterfaceDevice+".Managed", dbus.MakeVariant(managed))
+}
+
+func (b *dbusBackend) reapplyDevice(name string) error {
+	path, err := b.devicePath(name)
+	if err != nil {
+		return err
+	}
+	// Empty settings reapply the applied connection with its current profile
+	return b.object(path).Call(dbusInterfaceDevice+".Reapply", 0, dbusSettings{}, uint64(0), uint32(0)).Err
+}
+
+func (b *dbusBackend) requestWifiScan(deviceName string) error {
+	path, err := b.devicePath(deviceName)
+	if err != nil {
+		return err
+	}
+	device := b.object(path)
+	// LastScan is missing before NetworkManager 1.12, the scan can't be waited for then
+	lastScan, lastScanErr := device.GetProperty(dbusInterfaceWireless + ".LastScan")
+	if err := device.Call(dbusInterfaceWireless+".RequestScan", 0, map[string]dbus.Variant{}).Err; err != nil {
+		return err
+	}
+	if lastScanErr != nil {
+		log.Debug("Not waiting for wifi scan", "device", deviceName, "error", lastScanErr)
+		return nil
+	}
+
+	deadline := time.Now().Add(dbusScanTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(dbusActivationPollInterval)
+		variant, err := device.GetProperty(dbusInterfaceWireless + ".LastScan")
+		if err != nil {
+			return err
+		}
+		if variant.Value() != lastScan.Value() {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w while scanning on device %q", ErrTimeout, deviceName)
+}
+
+func (b *dbusBackend) wifiDevicePaths(deviceName string) ([]dbus.ObjectPath, error) {
+	if deviceName != "" {
+		path, err := b.devicePath(deviceName)
+		if err != nil {
+			return nil, err
+		}
+		return []dbus.ObjectPath{path}, nil
+	}
+
+	all := []dbus.ObjectPath{}
+	if err := b.object(dbusPath).Call(dbusInterface+".GetDevices", 0).Store(&all); err != nil {
+		return nil, err
+	}
+	paths := []dbus.ObjectPath{}
+	for _, path := range all {
+		variant, err := b.object(path).GetProperty(dbusInterfaceDevice + ".DeviceType")
+		if err != nil {
+			continue
+		}
+		if deviceType, _ := variant.Value().(uint32); deviceType == deviceTypeWifi {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+func (b *dbusBackend) listWifiNetworks(deviceName string) ([]WifiNetwork, error) {
+	devices, err := b.wifiDevicePaths(deviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	networks := []WifiNetwork{}
+	for _, device := range devices {
+		wireless, err := b.properties(device, dbusInterfaceWireless)
+		if err != nil {
+			return nil, err
+		}
+		activeAP, _ := wireless["ActiveAccessPoint"].Value().(dbus.ObjectPath)
+
+		accessPoints := []dbus.ObjectPath{}
+		if err := b.object(device).Call(dbusInterfaceWireless+".GetAllAccessPoints", 0).Store(&accessPoints); err != nil {
+			return nil, err
+		}
+		for _, ap := range accessPoints {
+			props, err := b.properties(ap, dbusInterfaceAccessPoint)
+			if err != nil {
+				log.Debug("Failed get access point", "path", ap, "error", err)
+				continue
+			}
+			network := accessPointToNetwork(props)
+			network.InUse = ap == activeAP
+			networks = append(networks, network)
+		}
+	}
+	return networks, nil
+}
+
+var accessPointModeNames = map[uint32]string{
+	1: "Ad-Hoc",
+	2: "Infra",
+	3: "AP",
+	4: "Mesh",
+}
+
+const accessPointFlagPrivacy = 0x1
+
+// Names of NM_802_11_AP_SEC_* flags as printed by nmcli
+var accessPointSecurityFlags = []struct {
+	flag uint32
+	name string
+}{
+	{0x1, "pair_wep40"},
+	{0x2, "pair_wep104"},
+	{0x4, "pair_tkip"},
+	{0x8, "pair_ccmp"},
+	{0x10, "group_wep40"},
+	{0x20, "group_wep104"},
+	{0x40, "group_tkip"},
+	{0x80, "group_ccmp"},
+	{0x100, "psk"},
+	{0x200, "802.1X"},
+	{0x400, "sae"},
+	{0x800, "owe"},
+	{0x1000, "owe_transition_mode"},
+	{0x2000, "eap_suite_b_192"},
+}
+
+const (
+	accessPointSecurityPSK   = 0x100
+	accessPointSecurity8021X = 0x200
+	accessPointSecuritySAE   = 0x400
+	accessPointSecurityOWE   = 0x800
+)
+
+func accessPointToNetwork(props map[string]dbus.Variant) WifiNetwork {
+	ssid, _ := props["Ssid"].Value().([]byte)
+	mode, _ := props["Mode"].Value().(uint32)
+	frequency, _ := props["Frequency"].Value().(uint32)
+	bitrate, _ := props["MaxBitrate"].Value().(uint32) // Kbit/s
+	strength, _ := props["Strength"].Value().(byte)
+	flags, _ := props["Flags"].Value().(uint32)
+	wpaFlags, _ := props["WpaFlags"].Value().(uint32)
+	rsnFlags, _ := props["RsnFlags"].Value().(uint32)
+
+	return WifiNetwork{
+		SSID:      string(ssid),
+		BSSID:     variantToOption(props["HwAddress"]),
+		Mode:      accessPointModeNames[mode],
+		Channel:   FrequencyChannel(int(frequency)),
+		Frequency: int(frequency),
+		Rate:      int(bitrate / 1000),
+		Signal:    uint(strength),
+		Security:  accessPointSecurity(flags, wpaFlags, rsnFlags),
+		WPAFlags:  accessPointFlagNames(wpaFlags),
+		RSNFlags:  accessPointFlagNames(rsnFlags),
+	}
+}
+
+// Same classification as SECURITY field of nmcli
+func accessPointSecurity(flags, wpaFlags, rsnFlags uint32) []string {
+	security := []string{}
+	if flags&accessPointFlagPrivacy != 0 && wpaFlags == 0 && rsnFlags == 0 {
+		security = append(security, "WEP")
+	}
+	if wpaFlags != 0 {
+		security = append(security, "WPA1")
+	}
+	if rsnFlags&(accessPointSecurityPSK|accessPointSecurity8021X) != 0 {
+		security = append(security, "WPA2")
+	}
+	if rsnFlags&accessPointSecuritySAE != 0 {
+		security = append(security, "WPA3")
+	}
+	if rsnFlags&accessPointSecurityOWE != 0 {
+		security = append(security, "OWE")
+	}
+	if (wpaFlags|rsnFlags)&accessPointSecurity8021X != 0 {
+		security = append(security, "802.1X")
+	}
+	return security
+}
+
+func accessPointFlagNames(flags uint32) []string {
+	names := []string{}
+	for _, f := range accessPointSecurityFlags {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// Converts D-Bus connection settings to options in nmcli format
+func settingsToOptions(settings dbusSettings) map[string]string {
+	options := map[string]string{}
+	for setting, props := range settings {
+		for prop, value := range props {
+			options[setting+"."+prop] = variantToOption(value)
+		}
+	}
+
+	for option, kind := range dbusOptionKinds {
+		if kind != dbusOptionTernary {
+			continue
+		}
+		if value, ok := options[option]; ok {
+			options[option] = map[string]string{"1": TrueValue, "0": "no", "-1": "default"}[value]
+		}
+	}
+	for option, names := range dbusOptionEnums {
+		if i, err := strconv.Atoi(options[option]); err == nil && i >= 0 && i < len(names) {
+			options[option] = names[i]
+		}
+	}
+
+	// nmcli shows address-data as addresses and DNS servers as IP addresses
+	for _, family := range []string{"ipv4", "ipv6"} {
+		if data, ok := settings[family]["address-data"]; ok {
+			options[family+".addresses"] = strings.Join(addressDataToStrings(data), ",")
+			delete(options, family+".address-data")
+		}
+		if data, ok := settings[family]["route-data"]; ok {
+			options[family+".routes"] = strings.Join(routeDataToStrings(data), ",")
+			delete(options, family+".route-data")
+		}
+		if data, ok := settings[family]["routing-rules"]; ok {
+			options[family+".routing-rules"] = strings.Join(routingRulesToStrings(data), ",")
+		}
+	}
+	if dns, ok := settings["ipv4"]["dns"].Value().([]uint32); ok {
+		servers := []string{}
+		for _, server := range dns {
+			servers = append(servers, uint32ToIP4(server).String())
+		}
+		options[OptionKeyDNSAddresses] = strings.Join(servers, ",")
+	}
+	if dns, ok := settings["ipv6"]["dns"].Value().([][]byte); ok {
+		servers := []string{}
+		for _, server := range dns {
+			servers = append(servers, net.IP(server).String())
+		}
+		options[OptionKeyIP6DNS] = strings.Join(servers, ",")
+	}
+
+	// Defaults are filled in for settings the profile has, like nmcli does
+	for option := range dbusOptionKinds {
+		setting, _, _ := strings.Cut(option, ".")
+		if _, ok := settings[setting]; !ok {
+			continue
+		}
+		if _, ok := options[option]; !ok {
+			options[option] = dbusOptionDefaults[option]
+		}
+	}
+	return options
+}
+
+func variantToOption(variant dbus.Variant) string {
+	switch value := variant.Value().(type) {
+	case nil:
+		return ""
+	case bool:
+		return formatBool(value)
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	case []string:
+		return strings.Join(value, ",")
+	case []uint32:
+		values := []string{}
+		for _, v := range value {
+			values = append(values, strconv.FormatUint(uint64(v), 10))
+		}
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprint(variant.Value())
+}
+
+func formatBool(value bool) string {
+	if value {
+		return TrueValue
+	}
+	return "no"
+}
+
+func addressDataToStrings(variant dbus.Variant) []string {
+	data, _ := variant.Value().([]map[string]dbus.Variant)
+	addresses := []string{}
+	for _, address := range data {
+		ip, _ := address["address"].Value().(string)
+		prefix, _ := address["prefix"].Value().(uint32)
+		addresses = append(addresses, fmt.Sprintf("%s/%d", ip, prefix))
+	}
+	return addresses
+}
+
+func routeDataToStrings(variant dbus.Variant) []string {
+	data, _ := variant.Value().([]map[string]dbus.Variant)
+	routes := []string{}
+	for _, attributes := range data {
+		dest, _ := attributes["dest"].Value().(string)
+		prefix, _ := attributes["prefix"].Value().(uint32)
+		fields := []string{fmt.Sprintf("%s/%d", dest, prefix)}
+		if nextHop, ok := attributes["next-hop"].Value().(string); ok {
+			fields = append(fields, nextHop)
+		}
+		if metric, ok := attributes["metric"].Value().(uint32); ok {
+			fields = append(fields, strconv.FormatUint(uint64(metric), 10))
+		}
+		names := []string{}
+		for name := range attributes {
+			if !slices.Contains([]string{"dest", "prefix", "next-hop", "metric"}, name) {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fields = append(fields, name+"="+variantToOption(attributes[name]))
+		}
+		routes = append(routes, strings.Join(fields, " "))
+	}
+	return routes
+}
+
+func routingRulesToStrings(variant dbus.Variant) []string {
+	data, _ := variant.Value().([]map[string]dbus.Variant)
+	rules := []string{}
+	for _, attributes := range data {
+		rule := RoutingRule{Extra: []string{}}
+		rule.Priority = int(variantUint32(attributes["priority"]))
+		rule.Table = int(variantUint32(attributes["table"]))
+		rule.Invert, _ = attributes["invert"].Value().(bool)
+		rule.IIF, _ = attributes["iifname"].Value().(string)
+		rule.OIF, _ = attributes["oifname"].Value().(string)
+		if from, ok := attributes["from"].Value().(string); ok {
+			rule.From = fmt.Sprintf("%s/%d", from, variantUint32(attributes["from-len"]))
+		}
+		if to, ok := attributes["to"].Value().(string); ok {
+			rule.To = fmt.Sprintf("%s/%d", to, variantUint32(attributes["to-len"]))
+		}
+		if mark, ok := attributes["fwmark"].Value().(uint32); ok {
+			rule.FWMark = fmt.Sprintf("%#x/%#x", mark, variantUint32(attributes["fwmask"]))
+		}
+		rules = append(rules, rule.String())
+	}
+	return rules
+}
+
+func variantUint32(variant dbus.Variant) uint32 {
+	switch value := variant.Value().(type) {
+	case uint32:
+		return value
+	case byte:
+		return uint32(value)
+	case int32:
+		return uint32(value)
+	}
+	return 0
+}
+
+// IPv4 addresses are stored as uint32 holding network order bytes, which
+// D-Bus marshals in host byte order
+func uint32ToIP4(value uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.NativeEndian.PutUint32(ip, value)
+	return ip
+}
+
+func ip4ToUint32(ip net.IP) uint32 {
+	return binary.NativeEndian.Uint32(ip.To4())
+}
+
+// Type of D-Bus value used for an option, needed when option is not set yet
+type dbusOptionKind int
+
+const (
+	dbusOptionString dbusOptionKind = iota
+	dbusOptionBool
+	dbusOptionUint32
+	dbusOptionInt32
+	dbusOptionInt64
+	dbusOptionBytes
+	dbusOptionStrings
+	dbusOptionAddresses
+	dbusOptionIP4List
+	dbusOptionIP6List
+	dbusOptionTernary
+	dbusOptionRoutes
+	dbusOptionRoutingRules
+)
+
+var dbusOptionKinds = map[string]dbusOptionKind{
+	OptionKeyAutoconnect:                   dbusOptionBool,
+	OptionKeyIP4Addresses:                  dbusOptionAddresses,
+	OptionKeyDNSAddresses:                  dbusOptionIP4List,
+	"ipv6.addresses":                       dbusOptionAddresses,
+	OptionKeyIP6DNS:                        dbusOptionIP6List,
+	OptionKeyIP4Routes:                     dbusOptionRoutes,
+	OptionKeyIP6Routes:                     dbusOptionRoutes,
+	OptionKeyIP4RoutingRules:               dbusOptionRoutingRules,
+	OptionKeyIP6RoutingRules:               dbusOptionRoutingRules,
+	OptionKeyIP4RouteMetric:                dbusOptionInt64,
+	OptionKeyIP6RouteMetric:                dbusOptionInt64,
+	OptionKeyIP4RouteTable:                 dbusOptionUint32,
+	OptionKeyIP6RouteTable:                 dbusOptionUint32,
+	OptionKeyIP4DNSSearch:                  dbusOptionStrings,
+	OptionKeyIP6DNSSearch:                  dbusOptionStrings,
+	OptionKeyIP4DNSOptions:                 dbusOptionStrings,
+	OptionKeyIP6DNSOptions:                 dbusOptionStrings,
+	OptionKeyIP4DNSPriority:                dbusOptionInt32,
+	OptionKeyIP6DNSPriority:                dbusOptionInt32,
+	OptionKeyIP4IgnoreAutoDNS:              dbusOptionBool,
+	OptionKeyIP6IgnoreAutoDNS:              dbusOptionBool,
+	OptionKeySharedDHCPRange:               dbusOptionString,
+	OptionKeySharedDHCPLease:               dbusOptionInt32,
+	OptionKeyWirelessSSID:                  dbusOptionBytes,
+	OptionKeyWirelessHidden:                dbusOptionBool,
+	OptionKeyWirelessChanel:                dbusOptionUint32,
+	OptionKeyWirelessSecurityProto:         dbusOptionStrings,
+	OptionKeyWirelessSecurityGroup:         dbusOptionStrings,
+	OptionKeyWirelessSecurityPairwise:      dbusOptionStrings,
+	OptionKeyWirelessSeenBSSIDs:            dbusOptionStrings,
+	OptionKeyWirelessSecurityPMF:           dbusOptionInt32,
+	OptionKeyWirelessAPIsolation:           dbusOptionTernary,
+	OptionKeyWirelessSecurityKeyManagement: dbusOptionString,
+}
+
+// NetworkManager leaves options with default values out of GetSettings reply,
+// while nmcli shows them. Options missing here default to empty value.
+var dbusOptionDefaults = map[string]string{
+	OptionKeyAutoconnect:         TrueValue,
+	OptionKeyIP4RouteMetric:      strconv.Itoa(RouteMetricDefault),
+	OptionKeyIP6RouteMetric:      strconv.Itoa(RouteMetricDefault),
+	OptionKeyIP4RouteTable:       "0",
+	OptionKeyIP6RouteTable:       "0",
+	OptionKeyIP4DNSPriority:      "0",
+	OptionKeyIP6DNSPriority:      "0",
+	OptionKeyIP4IgnoreAutoDNS:    "no",
+	OptionKeyIP6IgnoreAutoDNS:    "no",
+	OptionKeySharedDHCPLease:     "0",
+	OptionKeyWirelessHidden:      "no",
+	OptionKeyWirelessChanel:      "0",
+	OptionKeyWirelessSecurityPMF: wirelessPMFValues[0],
+	OptionKeyWirelessAPIsolation: "default",
+}
+
+// Enum options are shown by nmcli as names but stored as numbers
+var dbusOptionEnums = map[string][]string{
+	OptionKeyWirelessSecurityPMF: wirelessPMFValues,
+}
+
+var signatureKinds = map[string]dbusOptionKind{
+	"s":  dbusOptionString,
+	"b":  dbusOptionBool,
+	"u":  dbusOptionUint32,
+	"i":  dbusOptionInt32,
+	"x":  dbusOptionInt64,
+	"ay": dbusOptionBytes,
+	"as": dbusOptionStrings,
+}
+
+// Sets option in nmcli format to D-Bus connection settings. Empty value
+// removes the option, so NetworkManager falls back to its default.
+func setSettingsOption(settings dbusSettings, optionName, optionValue string) error {
+	setting, prop, ok := strings.Cut(optionName, ".")
+	if !ok {
+		return fmt.Errorf("invalid option %q", optionName)
+	}
+	if _, ok := settings[setting]; !ok {
+		settings[setting] = map[string]dbus.Variant{}
+	}
+
+	kind, ok := dbusOptionKinds[optionName]
+	if current, exists := settings[setting][prop]; !ok && exists {
+		kind = signatureKinds[current.Signature().String()]
+	}
+
+	if kind == dbusOptionAddresses {
+		delete(settings[setting], "addresses")
+		prop = "address-data"
+	}
+	if kind == dbusOptionRoutes {
+		delete(settings[setting], "routes")
+		prop = "route-data"
+	}
+	if optionValue == "" {
+		delete(settings[setting], prop)
+		return nil
+	}
+
+	if names, ok := dbusOptionEnums[optionName]; ok {
+		if i := slices.Index(names, optionValue); i >= 0 {
+			optionValue = strconv.Itoa(i)
+		}
+	}
+	value, err := optionToValue(kind, optionValue)
+	if err != nil {
+		return fmt.Errorf("invalid value %q of option %q: %s", optionValue, optionName, err)
+	}
+	if rules, ok := value.([]map[string]dbus.Variant); ok && kind == dbusOptionRoutingRules {
+		family := int32(2) // AF_INET
+		if setting == "ipv6" {
+			family = 10 // AF_INET6
+		}
+		for _, rule := range rules {
+			rule["family"] = dbus.MakeVariant(family)
+		}
+	}
+	settings[setting][prop] = dbus.MakeVariant(value)
+	return nil
+}
+
+func optionToValue(kind dbusOptionKind, value string) (any, error) {
+	switch kind {
+	case dbusOptionBool:
+		switch strings.ToLower(value) {
+		case "yes", "true", "on", "1":
+			return true, nil
+		case "no", "false", "off", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("not a boolean")
+	case dbusOptionUint32:
+		v, err := strconv.ParseUint(value, 10, 32)
+		return uint32(v), err
+	case dbusOptionInt32:
+		v, err := strconv.ParseInt(value, 10, 32)
+		return int32(v), err
+	case dbusOptionInt64:
+		return strconv.ParseInt(value, 10, 64)
+	case dbusOptionTernary:
+		switch strings.ToLower(value) {
+		case "yes", "true", "on", "1":
+			return int32(1), nil
+		case "no", "false", "off", "0":
+			return int32(0), nil
+		case "default", "-1":
+			return int32(-1), nil
+		}
+		return nil, fmt.Errorf("not a ternary value")
+	case dbusOptionBytes:
+		return []byte(value), nil
+	case dbusOptionStrings:
+		return splitList(value), nil
+	case dbusOptionAddresses:
+		data := []map[string]dbus.Variant{}
+		for _, address := range splitList(value) {
+			ip, network, err := net.ParseCIDR(address)
+			if err != nil {
+				return nil, err
+			}
+			prefix, _ := network.Mask.Size()
+			data = append(data, map[string]dbus.Variant{
+				"address": dbus.MakeVariant(ip.String()),
+				"prefix":  dbus.MakeVariant(uint32(prefix)),
+			})
+		}
+		return data, nil
+	case dbusOptionRoutes:
+		data := []map[string]dbus.Variant{}
+		for _, item := range splitOptionList(value) {
+			route, err := ParseRoute(item)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, routeToData(route))
+		}
+		return data, nil
+	case dbusOptionRoutingRules:
+		data := []map[string]dbus.Variant{}
+		for _, item := range splitOptionList(value) {
+			rule, err := ParseRoutingRule(item)
+			if err != nil {
+				return nil, err
+			}
+			attributes, err := routingRuleToData(rule)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, attributes)
+		}
+		return data, nil
+	case dbusOptionIP4List:
+		servers := []uint32{}
+		for _, server := range splitList(value) {
+			ip := net.ParseIP(server)
+			if ip == nil || ip.To4() == nil {
+				return nil, fmt.Errorf("invalid IPv4 address %q", server)
+			}
+			servers = append(servers, ip4ToUint32(ip))
+		}
+		return servers, nil
+	case dbusOptionIP6List:
+		servers := [][]byte{}
+		for _, server := range splitList(value) {
+			ip := net.ParseIP(server)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IPv6 address %q", server)
+			}
+			servers = append(servers, []byte(ip.To16()))
+		}
+		return servers, nil
+	}
+	return value, nil
+}
+
+func routeToData(route Route) map[string]dbus.Variant {
+	prefix, _ := route.Destination.Mask.Size()
+	attributes := map[string]dbus.Variant{
+		"dest":   dbus.MakeVariant(route.Destination.IP.String()),
+		"prefix": dbus.MakeVariant(uint32(prefix)),
+	}
+	if route.NextHop != nil {
+		attributes["next-hop"] = dbus.MakeVariant(route.NextHop.String())
+	}
+	if route.Metric != RouteMetricDefault {
+		attributes["metric"] = dbus.MakeVariant(uint32(route.Metric))
+	}
+	if route.Table != 0 {
+		attributes["table"] = dbus.MakeVariant(uint32(route.Table))
+	}
+	for name, value := range route.Attributes {
+		if n, err := strconv.ParseUint(value, 10, 32); err == nil {
+			attributes[name] = dbus.MakeVariant(uint32(n))
+		} else if b, err := strconv.ParseBool(value); err == nil {
+			attributes[name] = dbus.MakeVariant(b)
+		} else {
+			attributes[name] = dbus.MakeVariant(value)
+		}
+	}
+	return attributes
+}
+
+func routingRuleToData(rule RoutingRule) (map[string]dbus.Variant, error) {
+	if len(rule.Extra) > 0 {
+		return nil, fmt.Errorf("routing rule selectors %v are not supported over D-Bus", rule.Extra)
+	}
+	attributes := map[string]dbus.Variant{
+		"priority": dbus.MakeVariant(uint32(rule.Priority)),
+		"invert":   dbus.MakeVariant(rule.Invert),
+	}
+	if rule.Table != 0 {
+		attributes["table"] = dbus.MakeVariant(uint32(rule.Table))
+	}
+	if rule.IIF != "" {
+		attributes["iifname"] = dbus.MakeVariant(rule.IIF)
+	}
+	if rule.OIF != "" {
+		attributes["oifname"] = dbus.MakeVariant(rule.OIF)
+	}
+	for _, selector := range []struct{ name, value string }{{"from", rule.From}, {"to", rule.To}} {
+		if selector.value == "" || selector.value == "all" {
+			continue
+		}
+		network, err := parsePrefix(selector.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q in routing rule: %s", selector.name, selector.value, err)
+		}
+		length, _ := network.Mask.Size()
+		attributes[selector.name] = dbus.MakeVariant(network.IP.String())
+		attributes[selector.name+"-len"] = dbus.MakeVariant(byte(length))
+	}
+	if rule.FWMark != "" {
+		mark, mask, hasMask := strings.Cut(rule.FWMark, "/")
+		markValue, err := strconv.ParseUint(mark, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fwmark %q in routing rule", rule.FWMark)
+		}
+		maskValue := uint64(0xffffffff)
+		if hasMask {
+			if maskValue, err = strconv.ParseUint(mask, 0, 32); err != nil {
+				return nil, fmt.Errorf("invalid fwmark %q in routing rule", rule.FWMark)
+			}
+		}
+		attributes["fwmark"] = dbus.MakeVariant(uint32(markValue))
+		attributes["fwmask"] = dbus.MakeVariant(uint32(maskValue))
+	}
+	return attributes, nil
+}
+
+// Splits nmcli list values, which may be separated by commas or spaces
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+type nmcliAliases map[string]string
+
+func (a nmcliAliases) resolve(name string) string {
+	if resolved, ok := a[name]; ok {
+		return resolved
+	}
+	if setting, prop, ok := strings.Cut(name, "."); ok {
+		if resolved, ok := a[setting]; ok {
+			return resolved + "." + prop
+		}
+	}
+	return name
+}
+
+// Short names accepted by nmcli in place of settings and options
+var optionKeyAliases = nmcliAliases{
+	"autoconnect": OptionKeyAutoconnect,
+	"ssid":        OptionKeyWirelessSSID,
+	"mode":        OptionKeyWirelessMode,
+	"ip4":         OptionKeyIP4Addresses,
+	"gw4":         OptionKeyIP4Gateway,
+	"wifi":        "802-11-wireless",
+	"wifi-sec":    "802-11-wireless-security",
+	"ethernet":    "802-3-ethernet",
+	"con":         "connection",
+}
+
+var connectionTypeAliases = nmcliAliases{
+	string(ConnectionTypeWIFI):     string(ConnectionTypeWireless),
+	string(ConnectionTypeEthernet): "802-3-ethernet",
+}
+
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func (b *dbusBackend) version() (string, error) {
+	variant, err := b.object(dbusPath).GetProperty(dbusInterface + ".Version")
+	if err != nil {
+		return "", err
+	}
+	version, _ := variant.Value().(string)
+	return version, nil
+}
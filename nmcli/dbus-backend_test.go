@@ -0,0 +1,930 @@
+package nmcli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const fakeBusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// Starts private bus daemon and returns its address
+func startFakeBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	dir := t.TempDir()
+	config := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(config, []byte(fmt.Sprintf(fakeBusConfig, filepath.Join(dir, "bus"))), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+config, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed start dbus-daemon: %s", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed read bus address: %s", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+const (
+	fakeWiredUUID       = "5f8d2c3e-8a4b-4c1e-9d2f-1a2b3c4d5e6f"
+	fakeAccessPointUUID = "0c6e1a7d-3b9f-4e2a-8c5d-6f7a8b9c0d1e"
+	fakeUnreachableUUID = "9e8d7c6b-5a4f-4e3d-8c2b-1a0f9e8d7c6b"
+
+	fakeEthernetPath = dbus.ObjectPath("/org/freedesktop/NetworkManager/Devices/1")
+	fakeWifiPath     = dbus.ObjectPath("/org/freedesktop/NetworkManager/Devices/2")
+	fakeHomeAPPath   = dbus.ObjectPath("/org/freedesktop/NetworkManager/AccessPoint/1")
+	fakeCafeAPPath   = dbus.ObjectPath("/org/freedesktop/NetworkManager/AccessPoint/2")
+)
+
+// NetworkManager service holding connections, devices and access points in
+// memory. Only calls made by the D-Bus backend are implemented.
+type fakeNetworkManager struct {
+	conn  *dbus.Conn
+	mutex sync.Mutex
+	// Properties keyed by object path and interface
+	props       map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	connections map[dbus.ObjectPath]dbusSettings
+	checkpoints map[dbus.ObjectPath]fakeCheckpoint
+	lastID      int
+	scans       int
+	reapplied   []dbus.ObjectPath
+}
+
+type fakeCheckpoint struct {
+	devices []dbus.ObjectPath
+	timeout uint32
+	flags   uint32
+}
+
+// Starts fake NetworkManager on a private bus and returns backend talking to it
+func newFakeNetworkManager(t *testing.T) (*fakeNetworkManager, *dbusBackend) {
+	t.Helper()
+	address := startFakeBus(t)
+	server, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("failed connect to fake bus: %s", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	client, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("failed connect to fake bus: %s", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	nm := &fakeNetworkManager{
+		conn:        server,
+		props:       map[dbus.ObjectPath]map[string]map[string]dbus.Variant{},
+		connections: map[dbus.ObjectPath]dbusSettings{},
+		checkpoints: map[dbus.ObjectPath]fakeCheckpoint{},
+	}
+	nm.export(dbusPath, &fakeManager{nm}, dbusInterface, map[string]dbus.Variant{
+		"Version":           dbus.MakeVariant("1.46.0"),
+		"ActiveConnections": dbus.MakeVariant([]dbus.ObjectPath{}),
+	})
+	nm.export(dbusSettingsPath, &fakeSettings{nm}, dbusInterfaceSettings, nil)
+
+	nm.addDevice(fakeEthernetPath, map[string]dbus.Variant{
+		"Interface":       dbus.MakeVariant("eth0"),
+		"DeviceType":      dbus.MakeVariant(uint32(deviceTypeEthernet)),
+		"State":           dbus.MakeVariant(uint32(100)),
+		"Driver":          dbus.MakeVariant("e1000e"),
+		"HwAddress":       dbus.MakeVariant("52:54:00:12:34:56"),
+		"Mtu":             dbus.MakeVariant(uint32(1500)),
+		"Ip4Connectivity": dbus.MakeVariant(uint32(4)),
+		"Ip4Config":       dbus.MakeVariant(dbus.ObjectPath("/org/freedesktop/NetworkManager/IP4Config/1")),
+		"Ip6Config":       dbus.MakeVariant(dbusNoObject),
+	})
+	nm.export("/org/freedesktop/NetworkManager/IP4Config/1", nil, dbusInterfaceIP4Config, map[string]dbus.Variant{
+		"AddressData": dbus.MakeVariant([]map[string]dbus.Variant{{
+			"address": dbus.MakeVariant("192.168.1.10"),
+			"prefix":  dbus.MakeVariant(uint32(24)),
+		}}),
+		"Gateway":        dbus.MakeVariant("192.168.1.1"),
+		"NameserverData": dbus.MakeVariant([]map[string]dbus.Variant{{"address": dbus.MakeVariant("1.1.1.1")}}),
+		"Domains":        dbus.MakeVariant([]string{"lan"}),
+		"Searches":       dbus.MakeVariant([]string{}),
+	})
+
+	nm.addDevice(fakeWifiPath, map[string]dbus.Variant{
+		"Interface":  dbus.MakeVariant("wlan0"),
+		"DeviceType": dbus.MakeVariant(uint32(deviceTypeWifi)),
+		"State":      dbus.MakeVariant(uint32(30)),
+		"HwAddress":  dbus.MakeVariant(""),
+		"Ip4Config":  dbus.MakeVariant(dbusNoObject),
+		"Ip6Config":  dbus.MakeVariant(dbusNoObject),
+	})
+	nm.export(fakeWifiPath, &fakeWireless{nm}, dbusInterfaceWireless, map[string]dbus.Variant{
+		"HwAddress":            dbus.MakeVariant("02:00:00:aa:bb:cc"),
+		"WirelessCapabilities": dbus.MakeVariant(uint32(wifiDeviceCapabilityAP | 0x1)),
+		"ActiveAccessPoint":    dbus.MakeVariant(fakeHomeAPPath),
+		"LastScan":             dbus.MakeVariant(int64(1000)),
+	})
+	nm.export(fakeHomeAPPath, nil, dbusInterfaceAccessPoint, map[string]dbus.Variant{
+		"Ssid":       dbus.MakeVariant([]byte("Home")),
+		"HwAddress":  dbus.MakeVariant("10:20:30:40:50:60"),
+		"Mode":       dbus.MakeVariant(uint32(2)),
+		"Frequency":  dbus.MakeVariant(uint32(2437)),
+		"MaxBitrate": dbus.MakeVariant(uint32(130000)),
+		"Strength":   dbus.MakeVariant(byte(70)),
+		"Flags":      dbus.MakeVariant(uint32(accessPointFlagPrivacy)),
+		"WpaFlags":   dbus.MakeVariant(uint32(0)),
+		"RsnFlags":   dbus.MakeVariant(uint32(0x8 | 0x80 | accessPointSecurityPSK)),
+	})
+	nm.export(fakeCafeAPPath, nil, dbusInterfaceAccessPoint, map[string]dbus.Variant{
+		"Ssid":       dbus.MakeVariant([]byte("Cafe")),
+		"HwAddress":  dbus.MakeVariant("10:20:30:40:50:61"),
+		"Mode":       dbus.MakeVariant(uint32(2)),
+		"Frequency":  dbus.MakeVariant(uint32(5180)),
+		"MaxBitrate": dbus.MakeVariant(uint32(54000)),
+		"Strength":   dbus.MakeVariant(byte(35)),
+		"Flags":      dbus.MakeVariant(uint32(0)),
+		"WpaFlags":   dbus.MakeVariant(uint32(0)),
+		"RsnFlags":   dbus.MakeVariant(uint32(0)),
+	})
+
+	wired := nm.addConnection(dbusSettings{
+		"connection": {
+			"id":             dbus.MakeVariant("Wired"),
+			"uuid":           dbus.MakeVariant(fakeWiredUUID),
+			"type":           dbus.MakeVariant("802-3-ethernet"),
+			"interface-name": dbus.MakeVariant("eth0"),
+		},
+		"ipv4": {
+			"method": dbus.MakeVariant("auto"),
+			"dns":    dbus.MakeVariant([]uint32{ip4ToUint32(net.ParseIP("1.1.1.1"))}),
+		},
+	})
+	nm.addConnection(dbusSettings{
+		"connection": {
+			"id":             dbus.MakeVariant("Home AP"),
+			"uuid":           dbus.MakeVariant(fakeAccessPointUUID),
+			"type":           dbus.MakeVariant("802-11-wireless"),
+			"interface-name": dbus.MakeVariant("wlan0"),
+			"autoconnect":    dbus.MakeVariant(false),
+		},
+		"802-11-wireless": {
+			"ssid": dbus.MakeVariant([]byte("Home")),
+			"mode": dbus.MakeVariant("ap"),
+		},
+		"802-11-wireless-security": {
+			"key-mgmt": dbus.MakeVariant("wpa-psk"),
+			"psk":      dbus.MakeVariant("secret123"),
+		},
+		"ipv4": {
+			"method": dbus.MakeVariant("shared"),
+		},
+	})
+	nm.addConnection(dbusSettings{
+		"connection": {
+			"id":             dbus.MakeVariant("Unreachable"),
+			"uuid":           dbus.MakeVariant(fakeUnreachableUUID),
+			"type":           dbus.MakeVariant("802-11-wireless"),
+			"interface-name": dbus.MakeVariant("wlan0"),
+		},
+		"802-11-wireless": {
+			"ssid": dbus.MakeVariant([]byte("Nowhere")),
+		},
+	})
+	if _, err := (&fakeManager{nm}).ActivateConnection(wired, dbusNoObject, dbusNoObject); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := server.RequestName(dbusDestination, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("failed own %s: %v", dbusDestination, err)
+	}
+	return nm, &dbusBackend{conn: client}
+}
+
+// Exports methods of v and properties of the object's interface
+func (nm *fakeNetworkManager) export(path dbus.ObjectPath, v any, iface string, props map[string]dbus.Variant) {
+	if v != nil {
+		nm.conn.Export(v, path, iface)
+	}
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+	if _, ok := nm.props[path]; !ok {
+		nm.props[path] = map[string]map[string]dbus.Variant{}
+		nm.conn.Export(&fakeProperties{nm: nm, path: path}, path, dbusInterfaceProperties)
+	}
+	if props == nil {
+		props = map[string]dbus.Variant{}
+	}
+	nm.props[path][iface] = props
+}
+
+func (nm *fakeNetworkManager) unexport(path dbus.ObjectPath, ifaces ...string) {
+	for _, iface := range append(ifaces, dbusInterfaceProperties) {
+		nm.conn.Export(nil, path, iface)
+	}
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+	delete(nm.props, path)
+}
+
+func (nm *fakeNetworkManager) addDevice(path dbus.ObjectPath, props map[string]dbus.Variant) {
+	props["Managed"] = dbus.MakeVariant(true)
+	props["ActiveConnection"] = dbus.MakeVariant(dbusNoObject)
+	props["StateReason"] = dbus.MakeVariant(fakeStateReason{State: props["State"].Value().(uint32)})
+	nm.export(path, &fakeDevice{nm: nm, path: path}, dbusInterfaceDevice, props)
+}
+
+func (nm *fakeNetworkManager) addConnection(settings dbusSettings) dbus.ObjectPath {
+	nm.lastID++
+	path := dbus.ObjectPath(fmt.Sprintf("%s/%d", dbusSettingsPath, nm.lastID))
+	nm.connections[path] = settings
+	nm.conn.Export(&fakeSettingsConnection{nm: nm, path: path}, path, dbusInterfaceSettingsConn)
+	return path
+}
+
+// Runs f with the service locked, as calls are handled concurrently
+func (nm *fakeNetworkManager) inspect(f func()) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+	f()
+}
+
+func (nm *fakeNetworkManager) prop(path dbus.ObjectPath, iface, name string) any {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+	return nm.props[path][iface][name].Value()
+}
+
+func (nm *fakeNetworkManager) activeConnections() []dbus.ObjectPath {
+	return nm.prop(dbusPath, dbusInterface, "ActiveConnections").([]dbus.ObjectPath)
+}
+
+func (nm *fakeNetworkManager) devicePath(name string) (dbus.ObjectPath, bool) {
+	for path, ifaces := range nm.props {
+		if device, ok := ifaces[dbusInterfaceDevice]; ok && device["Interface"].Value() == name {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+type fakeStateReason struct {
+	State  uint32
+	Reason uint32
+}
+
+type fakeProperties struct {
+	nm   *fakeNetworkManager
+	path dbus.ObjectPath
+}
+
+func (p *fakeProperties) Get(iface, name string) (dbus.Variant, *dbus.Error) {
+	p.nm.mutex.Lock()
+	defer p.nm.mutex.Unlock()
+	value, ok := p.nm.props[p.path][iface][name]
+	if !ok {
+		return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []any{name})
+	}
+	return value, nil
+}
+
+func (p *fakeProperties) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	p.nm.mutex.Lock()
+	defer p.nm.mutex.Unlock()
+	props, ok := p.nm.props[p.path][iface]
+	if !ok {
+		return nil, dbus.NewError("org.freedesktop.DBus.Error.UnknownInterface", []any{iface})
+	}
+	return props, nil
+}
+
+func (p *fakeProperties) Set(iface, name string, value dbus.Variant) *dbus.Error {
+	p.nm.mutex.Lock()
+	defer p.nm.mutex.Unlock()
+	if _, ok := p.nm.props[p.path][iface][name]; !ok {
+		return dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []any{name})
+	}
+	p.nm.props[p.path][iface][name] = value
+	return nil
+}
+
+type fakeManager struct {
+	nm *fakeNetworkManager
+}
+
+// Activation succeeds immediately unless SSID of the connection isn't found
+func (m *fakeManager) ActivateConnection(connection, device, specific dbus.ObjectPath) (dbus.ObjectPath, *dbus.Error) {
+	nm := m.nm
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+	settings, ok := nm.connections[connection]
+	if !ok {
+		return "", dbus.NewError("org.freedesktop.NetworkManager.UnknownConnection", []any{string(connection)})
+	}
+	iface, _ := settings["connection"]["interface-name"].Value().(string)
+	devicePath, ok := nm.devicePath(iface)
+	if !ok {
+		return "", dbus.NewError("org.freedesktop.NetworkManager.UnknownDevice", []any{iface})
+	}
+
+	state := uint32(activeConnectionStateActivated)
+	if ssid, _ := settings["802-11-wireless"]["ssid"].Value().([]byte); string(ssid) == "Nowhere" {
+		state = activeConnectionStateDeactivated
+		nm.props[devicePath][dbusInterfaceDevice]["StateReason"] = dbus.MakeVariant(fakeStateReason{State: 30, Reason: 53})
+	}
+	nm.lastID++
+	path := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/NetworkManager/ActiveConnection/%d", nm.lastID))
+	nm.mutex.Unlock()
+	nm.export(path, nil, dbusInterfaceActiveConnection, map[string]dbus.Variant{
+		"Id":      settings["connection"]["id"],
+		"Uuid":    settings["connection"]["uuid"],
+		"State":   dbus.MakeVariant(state),
+		"Devices": dbus.MakeVariant([]dbus.ObjectPath{devicePath}),
+	})
+	nm.mutex.Lock()
+	if state == activeConnectionStateActivated {
+		active := nm.props[dbusPath][dbusInterface]["ActiveConnections"].Value().([]dbus.ObjectPath)
+		nm.props[dbusPath][dbusInterface]["ActiveConnections"] = dbus.MakeVariant(append(active, path))
+		nm.props[devicePath][dbusInterfaceDevice]["ActiveConnection"] = dbus.MakeVariant(path)
+	}
+	return path, nil
+}
+
+func (m *fakeManager) DeactivateConnection(path dbus.ObjectPath) *dbus.Error {
+	nm := m.nm
+	nm.mutex.Lock()
+	active := nm.props[dbusPath][dbusInterface]["ActiveConnections"].Value().([]dbus.ObjectPath)
+	i := slices.Index(active, path)
+	if i < 0 {
+		nm.mutex.Unlock()
+		return dbus.NewError("org.freedesktop.NetworkManager.ConnectionNotActive", []any{string(path)})
+	}
+	nm.props[dbusPath][dbusInterface]["ActiveConnections"] = dbus.MakeVariant(slices.Delete(active, i, i+1))
+	for _, device := range nm.props[path][dbusInterfaceActiveConnection]["Devices"].Value().([]dbus.ObjectPath) {
+		nm.props[device][dbusInterfaceDevice]["ActiveConnection"] = dbus.MakeVariant(dbusNoObject)
+	}
+	nm.mutex.Unlock()
+	nm.unexport(path)
+	return nil
+}
+
+func (m *fakeManager) GetDevices() ([]dbus.ObjectPath, *dbus.Error) {
+	return []dbus.ObjectPath{fakeEthernetPath, fakeWifiPath}, nil
+}
+
+func (m *fakeManager) GetDeviceByIpIface(name string) (dbus.ObjectPath, *dbus.Error) {
+	m.nm.mutex.Lock()
+	defer m.nm.mutex.Unlock()
+	path, ok := m.nm.devicePath(name)
+	if !ok {
+		return "", dbus.NewError("org.freedesktop.NetworkManager.UnknownDevice", []any{"No device found for the requested iface."})
+	}
+	return path, nil
+}
+
+func (m *fakeManager) CheckpointCreate(devices []dbus.ObjectPath, timeout, flags uint32) (dbus.ObjectPath, *dbus.Error) {
+	m.nm.mutex.Lock()
+	defer m.nm.mutex.Unlock()
+	if len(devices) == 0 {
+		devices = []dbus.ObjectPath{fakeEthernetPath, fakeWifiPath}
+	}
+	m.nm.lastID++
+	path := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/NetworkManager/Checkpoint/%d", m.nm.lastID))
+	m.nm.checkpoints[path] = fakeCheckpoint{devices: devices, timeout: timeout, flags: flags}
+	return path, nil
+}
+
+func (m *fakeManager) checkpoint(path dbus.ObjectPath) (fakeCheckpoint, *dbus.Error) {
+	checkpoint, ok := m.nm.checkpoints[path]
+	if !ok {
+		return checkpoint, dbus.NewError("org.freedesktop.NetworkManager.InvalidArguments", []any{"checkpoint does not exist"})
+	}
+	return checkpoint, nil
+}
+
+func (m *fakeManager) CheckpointRollback(path dbus.ObjectPath) (map[string]uint32, *dbus.Error) {
+	m.nm.mutex.Lock()
+	defer m.nm.mutex.Unlock()
+	checkpoint, err := m.checkpoint(path)
+	if err != nil {
+		return nil, err
+	}
+	delete(m.nm.checkpoints, path)
+	results := map[string]uint32{}
+	for _, device := range checkpoint.devices {
+		results[string(device)] = uint32(CheckpointRollbackSucceeded)
+	}
+	return results, nil
+}
+
+func (m *fakeManager) CheckpointDestroy(path dbus.ObjectPath) *dbus.Error {
+	m.nm.mutex.Lock()
+	defer m.nm.mutex.Unlock()
+	if _, err := m.checkpoint(path); err != nil {
+		return err
+	}
+	delete(m.nm.checkpoints, path)
+	return nil
+}
+
+func (m *fakeManager) CheckpointAdjustRollbackTimeout(path dbus.ObjectPath, timeout uint32) *dbus.Error {
+	m.nm.mutex.Lock()
+	defer m.nm.mutex.Unlock()
+	checkpoint, err := m.checkpoint(path)
+	if err != nil {
+		return err
+	}
+	checkpoint.timeout = timeout
+	m.nm.checkpoints[path] = checkpoint
+	return nil
+}
+
+type fakeSettings struct {
+	nm *fakeNetworkManager
+}
+
+func (s *fakeSettings) ListConnections() ([]dbus.ObjectPath, *dbus.Error) {
+	s.nm.mutex.Lock()
+	defer s.nm.mutex.Unlock()
+	paths := []dbus.ObjectPath{}
+	for path := range s.nm.connections {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+func (s *fakeSettings) GetConnectionByUuid(uuid string) (dbus.ObjectPath, *dbus.Error) {
+	s.nm.mutex.Lock()
+	defer s.nm.mutex.Unlock()
+	for path, settings := range s.nm.connections {
+		if settings["connection"]["uuid"].Value() == uuid {
+			return path, nil
+		}
+	}
+	return "", dbus.NewError("org.freedesktop.NetworkManager.Settings.InvalidConnection", []any{"No connection with the UUID was found."})
+}
+
+func (s *fakeSettings) AddConnection(settings dbusSettings) (dbus.ObjectPath, *dbus.Error) {
+	s.nm.mutex.Lock()
+	defer s.nm.mutex.Unlock()
+	return s.nm.addConnection(settings), nil
+}
+
+type fakeSettingsConnection struct {
+	nm   *fakeNetworkManager
+	path dbus.ObjectPath
+}
+
+// Secrets are left out like NetworkManager does, GetSecrets returns them
+func (c *fakeSettingsConnection) GetSettings() (dbusSettings, *dbus.Error) {
+	c.nm.mutex.Lock()
+	defer c.nm.mutex.Unlock()
+	settings := dbusSettings{}
+	for name, props := range c.nm.connections[c.path] {
+		settings[name] = map[string]dbus.Variant{}
+		for prop, value := range props {
+			if prop != "psk" {
+				settings[name][prop] = value
+			}
+		}
+	}
+	return settings, nil
+}
+
+func (c *fakeSettingsConnection) GetSecrets(name string) (dbusSettings, *dbus.Error) {
+	c.nm.mutex.Lock()
+	defer c.nm.mutex.Unlock()
+	secrets := dbusSettings{name: {}}
+	if psk, ok := c.nm.connections[c.path][name]["psk"]; ok {
+		secrets[name]["psk"] = psk
+	}
+	return secrets, nil
+}
+
+func (c *fakeSettingsConnection) Update(settings dbusSettings) *dbus.Error {
+	c.nm.mutex.Lock()
+	defer c.nm.mutex.Unlock()
+	c.nm.connections[c.path] = settings
+	return nil
+}
+
+func (c *fakeSettingsConnection) Delete() *dbus.Error {
+	c.nm.mutex.Lock()
+	delete(c.nm.connections, c.path)
+	c.nm.mutex.Unlock()
+	c.nm.conn.Export(nil, c.path, dbusInterfaceSettingsConn)
+	return nil
+}
+
+type fakeDevice struct {
+	nm   *fakeNetworkManager
+	path dbus.ObjectPath
+}
+
+func (d *fakeDevice) Reapply(settings dbusSettings, version uint64, flags uint32) *dbus.Error {
+	d.nm.mutex.Lock()
+	defer d.nm.mutex.Unlock()
+	d.nm.reapplied = append(d.nm.reapplied, d.path)
+	return nil
+}
+
+type fakeWireless struct {
+	nm *fakeNetworkManager
+}
+
+func (w *fakeWireless) RequestScan(options map[string]dbus.Variant) *dbus.Error {
+	w.nm.mutex.Lock()
+	defer w.nm.mutex.Unlock()
+	w.nm.scans++
+	w.nm.props[fakeWifiPath][dbusInterfaceWireless]["LastScan"] = dbus.MakeVariant(int64(1000 + w.nm.scans))
+	return nil
+}
+
+func (w *fakeWireless) GetAllAccessPoints() ([]dbus.ObjectPath, *dbus.Error) {
+	return []dbus.ObjectPath{fakeHomeAPPath, fakeCafeAPPath}, nil
+}
+
+func expectOptions(t *testing.T, c *keyValOutput, expected map[string]string) {
+	t.Helper()
+	for option, value := range expected {
+		if actual := c.getOption(option); actual != value {
+			t.Errorf("option %s = %q, expected %q", option, actual, value)
+		}
+	}
+}
+
+func TestDBusListConnections(t *testing.T) {
+	_, b := newFakeNetworkManager(t)
+	connections, err := b.listConnections()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Connection{
+		{Name: "Wired", UUID: fakeWiredUUID, Type: "802-3-ethernet", Device: "eth0"},
+		{Name: "Home AP", UUID: fakeAccessPointUUID, Type: ConnectionTypeWireless},
+		{Name: "Unreachable", UUID: fakeUnreachableUUID, Type: ConnectionTypeWireless},
+	}
+	if !slices.Equal(connections, expected) {
+		t.Errorf("connections = %v, expected %v", connections, expected)
+	}
+}
+
+func TestDBusShowConnection(t *testing.T) {
+	_, b := newFakeNetworkManager(t)
+
+	wired, err := b.showConnection("Wired")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wired.UUID != fakeWiredUUID || wired.Device != "eth0" {
+		t.Errorf("connection = %+v", wired)
+	}
+	expectOptions(t, wired.keyValOutput, map[string]string{
+		OptionKeyIP4Method:      ConnectionIP4MethodAuto,
+		OptionKeyDNSAddresses:   "1.1.1.1",
+		OptionKeyGeneralState:   ConnectionStateActivated,
+		"GENERAL.DEVICES":       "eth0",
+		OptionKeyAutoconnect:    TrueValue,
+		OptionKeyIP4RouteMetric: "-1",
+	})
+
+	ap, err := b.showConnection(fakeAccessPointUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOptions(t, ap.keyValOutput, map[string]string{
+		OptionKeyWirelessSSID:                  "Home",
+		OptionKeyWirelessMode:                  "ap",
+		OptionKeyWirelessHidden:                "no",
+		OptionKeyWirelessSecurityKeyManagement: "wpa-psk",
+		"802-11-wireless-security.psk":         "secret123",
+		OptionKeyAutoconnect:                   "no",
+		OptionKeyGeneralState:                  "",
+	})
+
+	if _, err := b.showConnection("Missing"); !errors.Is(err, ErrConnectionNotFound) {
+		t.Errorf("error = %v, expected %v", err, ErrConnectionNotFound)
+	}
+}
+
+func TestDBusAddConnection(t *testing.T) {
+	nm, b := newFakeNetworkManager(t)
+	err := b.addConnection(ConnectionTypeWIFI, "wlan0", "Guest", []string{
+		"ssid", "Guest",
+		"mode", "ap",
+		OptionKeyIP4Method, ConnectionIP4MethodShared,
+		"ip4", "10.42.0.1/24",
+		OptionKeyDNSAddresses, "9.9.9.9",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	guest, err := b.showConnection("Guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guest.Type != ConnectionTypeWireless || guest.Device != "wlan0" || len(guest.UUID) != 36 {
+		t.Errorf("connection = %+v", guest)
+	}
+	expectOptions(t, guest.keyValOutput, map[string]string{
+		OptionKeyWirelessSSID: "Guest",
+		OptionKeyIP4Method:    ConnectionIP4MethodShared,
+		OptionKeyIP4Addresses: "10.42.0.1/24",
+		OptionKeyDNSAddresses: "9.9.9.9",
+	})
+	var count int
+	nm.inspect(func() { count = len(nm.connections) })
+	if count != 4 {
+		t.Errorf("%d connections, expected 4", count)
+	}
+
+	if err := b.addConnection(ConnectionTypeEthernet, "eth0", "Odd", []string{"ipv4.method"}); err == nil {
+		t.Error("odd number of parameters accepted")
+	}
+}
+
+func TestDBusModifyConnection(t *testing.T) {
+	nm, b := newFakeNetworkManager(t)
+	ap := &Connection{Name: "Home AP", UUID: fakeAccessPointUUID}
+	err := b.modifyConnection(ap, []string{
+		OptionKeyWirelessHidden, TrueValue,
+		OptionKeyDNSAddresses, "8.8.8.8,8.8.4.4",
+		OptionKeyAutoconnect, "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified, err := b.showConnection("Home AP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOptions(t, modified.keyValOutput, map[string]string{
+		OptionKeyWirelessHidden:        TrueValue,
+		OptionKeyDNSAddresses:          "8.8.8.8,8.8.4.4",
+		OptionKeyAutoconnect:           TrueValue,
+		"802-11-wireless-security.psk": "secret123",
+	})
+
+	if err := b.modifyConnection(ap, []string{removeSettingParam, "wifi-sec"}); err != nil {
+		t.Fatal(err)
+	}
+	nm.inspect(func() {
+		for _, settings := range nm.connections {
+			if _, ok := settings["802-11-wireless-security"]; ok && settings["connection"]["uuid"].Value() == fakeAccessPointUUID {
+				t.Error("security setting isn't removed")
+			}
+		}
+	})
+
+	missing := &Connection{Name: "Missing", UUID: "00000000-0000-4000-8000-000000000000"}
+	if err := b.modifyConnection(missing, []string{OptionKeyAutoconnect, "no"}); !errors.Is(err, ErrConnectionNotFound) {
+		t.Errorf("error = %v, expected %v", err, ErrConnectionNotFound)
+	}
+}
+
+func TestDBusActivateConnection(t *testing.T) {
+	nm, b := newFakeNetworkManager(t)
+	ap := &Connection{Name: "Home AP", UUID: fakeAccessPointUUID}
+	if err := b.activateConnection(ap, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if active := nm.activeConnections(); len(active) != 2 {
+		t.Errorf("active connections = %v", active)
+	}
+	shown, err := b.showConnection("Home AP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOptions(t, shown.keyValOutput, map[string]string{
+		OptionKeyGeneralState: ConnectionStateActivated,
+		"GENERAL.DEVICES":     "wlan0",
+	})
+
+	unreachable := &Connection{Name: "Unreachable", UUID: fakeUnreachableUUID}
+	err = b.activateConnection(unreachable, time.Second)
+	if !errors.Is(err, ErrActivationFailed) || !strings.Contains(err.Error(), "ssid not found") {
+		t.Errorf("error = %v, expected %v with reason", err, ErrActivationFailed)
+	}
+}
+
+func TestDBusDeactivateConnection(t *testing.T) {
+	nm, b := newFakeNetworkManager(t)
+	wired := &Connection{Name: "Wired", UUID: fakeWiredUUID}
+	if err := b.deactivateConnection(wired); err != nil {
+		t.Fatal(err)
+	}
+	if active := nm.activeConnections(); len(active) != 0 {
+		t.Errorf("active connections = %v", active)
+	}
+	if path := nm.prop(fakeEthernetPath, dbusInterfaceDevice, "ActiveConnection"); path != dbusNoObject {
+		t.Errorf("device active connection = %v", path)
+	}
+	if err := b.deactivateConnection(wired); err == nil {
+		t.Error("inactive connection deactivated")
+	}
+}
+
+func TestDBusDeleteConnection(t *testing.T) {
+	nm, b := newFakeNetworkManager(t)
+	ap := &Connection{Name: "Home AP", UUID: fakeAccessPointUUID}
+	if err := b.deleteConnection(ap); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	nm.inspect(func() { count = len(nm.connections) })
+	if count != 2 {
+		t.Errorf("%d connections, expected 2", count)
+	}
+	if _, err := b.showConnection("Home AP"); !errors.Is(err, ErrConnectionNotFound) {
+		t.Errorf("error = %v, expected %v", err, ErrConnectionNotFound)
+	}
+	if err := b.deleteConnection(ap); !errors.Is(err, ErrConnectionNotFound) {
+		t.Errorf("error = %v, expected %v", err, ErrConnectionNotFound)
+	}
+}
+
+func TestDBusDevices(t *testing.T) {
+	nm, b := newFakeNetworkManager(t)
+	devices, err := b.listDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(devices, []string{"eth0", "wlan0"}) {
+		t.Errorf("devices = %v", devices)
+	}
+
+	eth0, err := b.showDevice("eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOptions(t, eth0.keyValOutput, map[string]string{
+		"GENERAL.DEVICE":         "eth0",
+		"GENERAL.TYPE":           "ethernet",
+		"GENERAL.STATE":          "100 (connected)",
+		"GENERAL.HWADDR":         "52:54:00:12:34:56",
+		"GENERAL.MTU":            "1500",
+		"GENERAL.CONNECTION":     "Wired",
+		OptionKeyIP4Connectivity: "4 (full)",
+		"IP4.ADDRESS[1]":         "192.168.1.10/24",
+		"IP4.GATEWAY":            "192.168.1.1",
+		"IP4.DNS[1]":             "1.1.1.1",
+		"IP4.DOMAIN[1]":          "lan",
+	})
+	wlan0, err := b.showDevice("wlan0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOptions(t, wlan0.keyValOutput, map[string]string{
+		"GENERAL.TYPE":            "wifi",
+		"GENERAL.STATE":           "30 (disconnected)",
+		"GENERAL.HWADDR":          "02:00:00:aa:bb:cc",
+		OptionKeyCanBeAccessPoint: TrueValue,
+	})
+	if _, err := b.showDevice("wlan9"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("error = %v, expected %v", err, ErrDeviceNotFound)
+	}
+
+	if err := b.setDeviceManaged("wlan0", false); err != nil {
+		t.Fatal(err)
+	}
+	if managed := nm.prop(fakeWifiPath, dbusInterfaceDevice, "Managed"); managed != false {
+		t.Errorf("managed = %v", managed)
+	}
+	if err := b.reapplyDevice("eth0"); err != nil {
+		t.Fatal(err)
+	}
+	nm.inspect(func() {
+		if !slices.Equal(nm.reapplied, []dbus.ObjectPath{fakeEthernetPath}) {
+			t.Errorf("reapplied = %v", nm.reapplied)
+		}
+	})
+}
+
+func TestDBusAccessPoints(t *testing.T) {
+	nm, b := newFakeNetworkManager(t)
+	if err := b.requestWifiScan("wlan0"); err != nil {
+		t.Fatal(err)
+	}
+	nm.inspect(func() {
+		if nm.scans != 1 {
+			t.Errorf("%d scans requested, expected 1", nm.scans)
+		}
+	})
+
+	networks, err := b.listWifiNetworks("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 2 {
+		t.Fatalf("networks = %+v", networks)
+	}
+	home, cafe := networks[0], networks[1]
+	if !home.InUse || home.SSID != "Home" || home.BSSID != "10:20:30:40:50:60" || home.Mode != "Infra" ||
+		home.Channel != 6 || home.Rate != 130 || home.Signal != 70 {
+		t.Errorf("network = %+v", home)
+	}
+	if !slices.Equal(home.Security, []string{"WPA2"}) || !slices.Equal(home.RSNFlags, []string{"pair_ccmp", "group_ccmp", "psk"}) {
+		t.Errorf("security = %v, RSN flags = %v", home.Security, home.RSNFlags)
+	}
+	if cafe.InUse || !cafe.IsOpen() || cafe.Channel != 36 || cafe.Frequency != 5180 {
+		t.Errorf("network = %+v", cafe)
+	}
+
+	if _, err := b.listWifiNetworks("wlan9"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("error = %v, expected %v", err, ErrDeviceNotFound)
+	}
+}
+
+func TestDBusCheckpoints(t *testing.T) {
+	nm, b := newFakeNetworkManager(t)
+	previous := currentBackend
+	t.Cleanup(func() {
+		currentBackend = previous
+		forgetVersion()
+	})
+
+	currentBackend = &cliBackend{}
+	if _, err := CreateCheckpoint(nil, time.Minute, CheckpointFlagNone); err == nil {
+		t.Error("checkpoint created without D-Bus backend")
+	}
+	if err := UseDBusBackend(b.conn); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint, err := CreateCheckpoint([]string{"eth0"}, time.Minute, CheckpointFlagDestroyAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created fakeCheckpoint
+	nm.inspect(func() { created = nm.checkpoints[checkpoint.path] })
+	if !slices.Equal(created.devices, []dbus.ObjectPath{fakeEthernetPath}) || created.timeout != 60 ||
+		created.flags != uint32(CheckpointFlagDestroyAll) {
+		t.Errorf("checkpoint = %+v", created)
+	}
+	if err := checkpoint.AdjustRollbackTimeout(30 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	nm.inspect(func() { created = nm.checkpoints[checkpoint.path] })
+	if created.timeout != 30 {
+		t.Errorf("rollback timeout = %d, expected 30", created.timeout)
+	}
+	results, err := checkpoint.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results["eth0"] != CheckpointRollbackSucceeded {
+		t.Errorf("rollback results = %v", results)
+	}
+
+	all, err := CreateCheckpoint(nil, 0, CheckpointFlagNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nm.inspect(func() { created = nm.checkpoints[all.path] })
+	if len(created.devices) != 2 {
+		t.Errorf("checkpoint devices = %v", created.devices)
+	}
+	if err := all.Destroy(); err != nil {
+		t.Fatal(err)
+	}
+	if err := all.Destroy(); err == nil {
+		t.Error("destroyed checkpoint destroyed again")
+	}
+	if _, err := CreateCheckpoint([]string{"wlan9"}, time.Minute, CheckpointFlagNone); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("error = %v, expected %v", err, ErrDeviceNotFound)
+	}
+}
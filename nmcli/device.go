@@ -1,27 +1,85 @@
 package nmcli
 
 import (
-	"github.com/zarinit-routers/cli"
+	"strconv"
+	"strings"
 )
 
 type Device struct {
 	*keyValOutput
 }
 
+// Missing device is [ErrDeviceNotFound]
 func GetDevice(name string) (*Device, error) {
-	data, err := cli.Execute("nmcli", showSecretsFlag, terseFlag, allFieldsFlag, "device", "show", name)
-	if err != nil {
-		return nil, err
-	}
+	return currentBackend.showDevice(name)
+}
 
-	kv := newKeyValOutput(data)
-	return &Device{keyValOutput: kv}, nil
+// Lists names of devices known to NetworkManager
+func GetDeviceNames() ([]string, error) {
+	return currentBackend.listDevices()
+}
+
+// Applies changed options of the connection active on the device without
+// reactivating it, e.g. route metrics or DNS servers
+func ReapplyDevice(name string) error {
+	return currentBackend.reapplyDevice(name)
 }
 
 const (
 	OptionKeyCanBeAccessPoint = "WIFI-PROPERTIES.AP"
+	OptionKeyDeviceState      = "GENERAL.STATE"
+	OptionKeyIP4Connectivity  = "GENERAL.IP4-CONNECTIVITY"
 )
 
 func (d *Device) CanBeAccessPoint() bool {
 	return d.getOption(OptionKeyCanBeAccessPoint) == TrueValue
 }
+
+// Numeric device state, e.g. 100 for connected
+type DeviceStateCode = int
+
+const DeviceStateCodeConnected DeviceStateCode = 100
+
+// Returns state code and its name, nmcli shows state like "100 (connected)"
+func (d *Device) GetState() (DeviceStateCode, string) {
+	return parseCodeOption(d.getOption(OptionKeyDeviceState))
+}
+
+func (d *Device) IsConnected() bool {
+	code, _ := d.GetState()
+	return code == DeviceStateCodeConnected
+}
+
+type Connectivity = string
+
+// Internet connectivity NetworkManager detected, unknown if checking is disabled
+const (
+	ConnectivityUnknown Connectivity = "unknown"
+	ConnectivityNone    Connectivity = "none"
+	ConnectivityPortal  Connectivity = "portal"
+	ConnectivityLimited Connectivity = "limited"
+	ConnectivityFull    Connectivity = "full"
+)
+
+// Indexed by numeric values used on D-Bus
+var connectivityNames = []Connectivity{
+	ConnectivityUnknown, ConnectivityNone, ConnectivityPortal, ConnectivityLimited, ConnectivityFull,
+}
+
+func (d *Device) GetIP4Connectivity() Connectivity {
+	_, name := parseCodeOption(d.getOption(OptionKeyIP4Connectivity))
+	if name == "" {
+		return ConnectivityUnknown
+	}
+	return name
+}
+
+// Parses values like "100 (connected)" to the code and the name
+func parseCodeOption(value string) (int, string) {
+	codeValue, name, _ := strings.Cut(value, " ")
+	code, err := strconv.Atoi(codeValue)
+	if err != nil {
+		return 0, value
+	}
+	return code, strings.TrimSuffix(strings.TrimPrefix(name, "("), ")")
+}
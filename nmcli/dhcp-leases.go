@@ -0,0 +1,218 @@
+package nmcli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dnsmasq instances of shared connections keep leases in
+// dnsmasq-<device>.leases files there
+var DnsmasqLeaseDirectory = "/var/lib/NetworkManager"
+
+const (
+	dnsmasqLeasePrefix = "dnsmasq-"
+	dnsmasqLeaseSuffix = ".leases"
+	// Placeholder for unknown hostname and client id
+	dnsmasqLeaseNone = "*"
+)
+
+// Default period of checking lease files in [WatchDHCPLeases]
+const DHCPLeaseWatchInterval = 5 * time.Second
+
+type DHCPLease struct {
+	// Device of the shared connection which leased the address
+	Device   string           `json:"device"`
+	MAC      net.HardwareAddr `json:"mac"`
+	IP       net.IP           `json:"ip"`
+	Hostname string           `json:"hostname"`
+	ClientID string           `json:"clientId"`
+	// Zero for infinite leases
+	Expires time.Time `json:"expires"`
+}
+
+func (l *DHCPLease) Expired(now time.Time) bool {
+	return !l.Expires.IsZero() && !now.Before(l.Expires)
+}
+
+// Parses dnsmasq lease file, one "expiry mac ip hostname client-id" line per
+// lease. DHCPv6 leases are skipped.
+func ParseDHCPLeases(data []byte) ([]DHCPLease, error) {
+	leases := []DHCPLease{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] == "duid" {
+			continue
+		}
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("invalid lease %q", scanner.Text())
+		}
+		ip := net.ParseIP(fields[2])
+		if ip == nil {
+			return nil, fmt.Errorf("invalid lease address %q", fields[2])
+		}
+		if ip.To4() == nil {
+			continue
+		}
+		expiry, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lease expiry %q", fields[0])
+		}
+		mac, err := net.ParseMAC(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid lease MAC address %q", fields[1])
+		}
+		lease := DHCPLease{MAC: mac, IP: ip.To4()}
+		if expiry != 0 {
+			lease.Expires = time.Unix(expiry, 0)
+		}
+		if fields[3] != dnsmasqLeaseNone {
+			lease.Hostname = fields[3]
+		}
+		if fields[4] != dnsmasqLeaseNone {
+			lease.ClientID = fields[4]
+		}
+		leases = append(leases, lease)
+	}
+	return leases, scanner.Err()
+}
+
+// Returns current leases given by shared connection on device
+func GetDHCPLeases(device string) ([]DHCPLease, error) {
+	data, err := os.ReadFile(dhcpLeaseFile(device))
+	if errors.Is(err, os.ErrNotExist) {
+		return []DHCPLease{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed read DHCP leases: %s", err)
+	}
+	leases, err := ParseDHCPLeases(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed parse DHCP leases of %q: %s", device, err)
+	}
+	// dnsmasq removes expired leases from the file only when it is rewritten
+	now := time.Now()
+	current := []DHCPLease{}
+	for _, lease := range leases {
+		if !lease.Expired(now) {
+			lease.Device = device
+			current = append(current, lease)
+		}
+	}
+	return current, nil
+}
+
+// Returns current leases of all shared connections keyed by device
+func GetAllDHCPLeases() (map[string][]DHCPLease, error) {
+	files, err := filepath.Glob(filepath.Join(DnsmasqLeaseDirectory, dnsmasqLeasePrefix+"*"+dnsmasqLeaseSuffix))
+	if err != nil {
+		return nil, err
+	}
+	all := map[string][]DHCPLease{}
+	for _, file := range files {
+		device := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), dnsmasqLeasePrefix), dnsmasqLeaseSuffix)
+		leases, err := GetDHCPLeases(device)
+		if err != nil {
+			return nil, err
+		}
+		all[device] = leases
+	}
+	return all, nil
+}
+
+// Returns current leases of the shared connection, none if it isn't active
+func (c *Connection) GetDHCPLeases() ([]DHCPLease, error) {
+	device := c.GetActiveDevice()
+	if device == "" {
+		return []DHCPLease{}, nil
+	}
+	return GetDHCPLeases(device)
+}
+
+func dhcpLeaseFile(device string) string {
+	return filepath.Join(DnsmasqLeaseDirectory, dnsmasqLeasePrefix+device+dnsmasqLeaseSuffix)
+}
+
+type DHCPLeaseEventType = string
+
+const (
+	DHCPLeaseEventAdded DHCPLeaseEventType = "added"
+	// Lease expired or was released by the client
+	DHCPLeaseEventExpired DHCPLeaseEventType = "expired"
+)
+
+type DHCPLeaseEvent struct {
+	Type  DHCPLeaseEventType `json:"type"`
+	Time  time.Time          `json:"time"`
+	Lease DHCPLease          `json:"lease"`
+}
+
+// Checks lease files of all shared connections every interval and reports
+// leases which appeared or went away since the previous check. Leases present
+// on start are reported as added. Channel is closed when ctx is done.
+func WatchDHCPLeases(ctx context.Context, interval time.Duration) <-chan DHCPLeaseEvent {
+	if interval <= 0 {
+		interval = DHCPLeaseWatchInterval
+	}
+	events := make(chan DHCPLeaseEvent)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		known := map[string]DHCPLease{}
+		for {
+			current, err := GetAllDHCPLeases()
+			if err != nil {
+				log.Warn("Failed read DHCP leases", "error", err)
+			} else {
+				for _, event := range diffDHCPLeases(known, current) {
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return events
+}
+
+// Updates known leases to current ones and returns the difference. Renewal of
+// the same address by the same client isn't reported.
+func diffDHCPLeases(known map[string]DHCPLease, current map[string][]DHCPLease) []DHCPLeaseEvent {
+	now := time.Now()
+	events := []DHCPLeaseEvent{}
+	seen := map[string]bool{}
+	for _, leases := range current {
+		for _, lease := range leases {
+			key := lease.Device + " " + lease.MAC.String() + " " + lease.IP.String()
+			seen[key] = true
+			if _, ok := known[key]; !ok {
+				events = append(events, DHCPLeaseEvent{Type: DHCPLeaseEventAdded, Time: now, Lease: lease})
+			}
+			known[key] = lease
+		}
+	}
+	for key, lease := range known {
+		if !seen[key] {
+			events = append(events, DHCPLeaseEvent{Type: DHCPLeaseEventExpired, Time: now, Lease: lease})
+			delete(known, key)
+		}
+	}
+	return events
+}
@@ -0,0 +1,188 @@
+package nmcli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const dhcpReservationsPrefix = "dhcp-hosts-"
+
+// Address always given to a LAN client by DHCP server of a shared connection
+type DHCPReservation struct {
+	MAC net.HardwareAddr `json:"mac"`
+	IP  net.IP           `json:"ip"`
+	// Name given to the client, optional
+	Hostname string `json:"hostname,omitempty"`
+}
+
+var hostnameRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// Returns reservations of the connection's DHCP server
+func (c *Connection) GetDHCPReservations() ([]DHCPReservation, error) {
+	data, err := os.ReadFile(c.dhcpReservationsFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return []DHCPReservation{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed read DHCP reservations: %s", err)
+	}
+	reservations := []DHCPReservation{}
+	for _, line := range strings.Split(string(data), "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "dhcp-host=")
+		if !ok {
+			continue
+		}
+		fields := strings.Split(value, ",")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid DHCP reservation %q", value)
+		}
+		mac, err := net.ParseMAC(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid DHCP reservation %q: %s", value, err)
+		}
+		reservation := DHCPReservation{MAC: mac, IP: net.ParseIP(fields[1])}
+		if len(fields) > 2 {
+			reservation.Hostname = fields[2]
+		}
+		reservations = append(reservations, reservation)
+	}
+	return reservations, nil
+}
+
+// Replaces reservations of the connection's DHCP server. Active connection
+// is reactivated to apply them, which briefly disconnects its clients.
+func (c *Connection) SetDHCPReservations(reservations []DHCPReservation) error {
+	if err := c.validateDHCPReservations(reservations); err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		if err := c.removeDHCPReservations(); err != nil {
+			return err
+		}
+		return c.applyDHCPServer()
+	}
+
+	lines := []string{"# Static DHCP leases of connection " + strconv.Quote(c.Name)}
+	for _, r := range reservations {
+		fields := []string{r.MAC.String(), r.IP.To4().String()}
+		if r.Hostname != "" {
+			fields = append(fields, r.Hostname)
+		}
+		lines = append(lines, "dhcp-host="+strings.Join(fields, ","))
+	}
+	content := strings.Join(append(lines, ""), "\n")
+	if err := os.MkdirAll(DnsmasqSharedDirectory, 0o755); err != nil {
+		return fmt.Errorf("failed create dnsmasq configuration directory: %s", err)
+	}
+	if err := os.WriteFile(c.dhcpReservationsFile(), []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed write DHCP reservations: %s", err)
+	}
+	return c.applyDHCPServer()
+}
+
+// Adds reservation, existing one for the same MAC address is replaced
+func (c *Connection) AddDHCPReservation(reservation DHCPReservation) error {
+	reservations, err := c.GetDHCPReservations()
+	if err != nil {
+		return err
+	}
+	kept := []DHCPReservation{}
+	for _, r := range reservations {
+		if !bytes.Equal(r.MAC, reservation.MAC) {
+			kept = append(kept, r)
+		}
+	}
+	return c.SetDHCPReservations(append(kept, reservation))
+}
+
+func (c *Connection) RemoveDHCPReservation(mac net.HardwareAddr) error {
+	reservations, err := c.GetDHCPReservations()
+	if err != nil {
+		return err
+	}
+	kept := []DHCPReservation{}
+	for _, r := range reservations {
+		if !bytes.Equal(r.MAC, mac) {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(reservations) {
+		return fmt.Errorf("no DHCP reservation for %s on connection %q", mac, c.Name)
+	}
+	return c.SetDHCPReservations(kept)
+}
+
+// Reservations must be host addresses of the connection's subnet. They may be
+// inside the DHCP pool, dnsmasq never leases reserved addresses to others.
+func (c *Connection) validateDHCPReservations(reservations []DHCPReservation) error {
+	if method := c.getOption(OptionKeyIP4Method); method != ConnectionIP4MethodShared {
+		return fmt.Errorf("DHCP server requires %s %q, connection %q has %q",
+			OptionKeyIP4Method, ConnectionIP4MethodShared, c.Name, method)
+	}
+	router, network, err := c.sharedAddress()
+	if err != nil {
+		return err
+	}
+	if network == nil {
+		return fmt.Errorf("DHCP reservations require address of connection %q", c.Name)
+	}
+
+	macs, ips := map[string]bool{}, map[string]bool{}
+	for _, r := range reservations {
+		ip := r.IP.To4()
+		switch {
+		case len(r.MAC) != 6:
+			return fmt.Errorf("invalid MAC address %q", r.MAC)
+		case ip == nil || !network.Contains(ip):
+			return fmt.Errorf("reserved address %s is outside of %s", r.IP, network)
+		case ip.Equal(router):
+			return fmt.Errorf("reserved address %s is router address", ip)
+		case ip.Equal(network.IP) || ip.Equal(broadcastAddress(network)):
+			return fmt.Errorf("reserved address %s is not a host address", ip)
+		case r.Hostname != "" && !hostnameRegex.MatchString(r.Hostname):
+			return fmt.Errorf("invalid hostname %q", r.Hostname)
+		case macs[r.MAC.String()]:
+			return fmt.Errorf("duplicate reservation for %s", r.MAC)
+		case ips[ip.String()]:
+			return fmt.Errorf("address %s is reserved twice", ip)
+		}
+		macs[r.MAC.String()], ips[ip.String()] = true, true
+	}
+	return nil
+}
+
+func broadcastAddress(network *net.IPNet) net.IP {
+	ip := make(net.IP, len(network.IP))
+	for i := range network.IP {
+		ip[i] = network.IP[i] | ^network.Mask[i]
+	}
+	return ip
+}
+
+// dnsmasq reads configuration on start only, reactivation restarts it
+func (c *Connection) applyDHCPServer() error {
+	if !c.IsActive() {
+		return nil
+	}
+	log.Info("Reactivating connection to apply DHCP configuration", "connection", c.Name)
+	return c.Up()
+}
+
+func (c *Connection) removeDHCPReservations() error {
+	err := os.Remove(c.dhcpReservationsFile())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed remove DHCP reservations: %s", err)
+	}
+	return nil
+}
+
+func (c *Connection) dhcpReservationsFile() string {
+	return filepath.Join(DnsmasqSharedDirectory, dhcpReservationsPrefix+c.UUID+".conf")
+}
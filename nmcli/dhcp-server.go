@@ -0,0 +1,240 @@
+package nmcli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Configuration files of dnsmasq instances NetworkManager runs for shared
+// connections. Files are read by all instances.
+var DnsmasqSharedDirectory = "/etc/NetworkManager/dnsmasq-shared.d"
+
+const dhcpDropInPrefix = "dhcp-"
+
+// dnsmasq refuses shorter leases
+const MinDHCPLeaseTime = 2 * time.Minute
+
+// DHCP pool of a connection with ipv4.method shared
+type DHCPServerConfig struct {
+	RangeStart net.IP `json:"rangeStart"`
+	RangeEnd   net.IP `json:"rangeEnd"`
+	// Zero means NetworkManager default of one hour
+	LeaseTime time.Duration `json:"leaseTime"`
+}
+
+// Sets DHCP pool of the shared connection, it is used after reactivation.
+//
+// NetworkManager before 1.42 can't configure the pool, so a dnsmasq drop-in
+// is written instead. dnsmasq keeps serving the default pool of the subnet
+// besides it then, so [UnsupportedError] is returned unless the range covers
+// the default pool. Lease time only applies to leases from the range.
+func (c *Connection) SetDHCPServer(config DHCPServerConfig) error {
+	if err := c.validateDHCPServer(config); err != nil {
+		return err
+	}
+	dhcpRange := config.RangeStart.String() + "," + config.RangeEnd.String()
+	leaseTime := strconv.Itoa(int(config.LeaseTime.Seconds()))
+
+	err := RequireCapability(CapabilitySharedDHCPRange)
+	if errors.Is(err, ErrUnsupported) {
+		if err := c.requireDropInCoversDefaultPool(config, err); err != nil {
+			return err
+		}
+		log.Warn("Using dnsmasq drop-in for DHCP pool", "connection", c.Name, "reason", err)
+		return c.writeDHCPDropIn(config)
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.setOptions(OptionKeySharedDHCPRange, dhcpRange, OptionKeySharedDHCPLease, leaseTime); err != nil {
+		return err
+	}
+	return c.removeDHCPDropIn()
+}
+
+// Returns DHCP pool set earlier, nil if NetworkManager default pool is used
+func (c *Connection) GetDHCPServer() (*DHCPServerConfig, error) {
+	if value := c.getOption(OptionKeySharedDHCPRange); value != "" {
+		config := &DHCPServerConfig{}
+		start, end, _ := strings.Cut(value, ",")
+		config.RangeStart, config.RangeEnd = net.ParseIP(start), net.ParseIP(end)
+		if config.RangeStart == nil || config.RangeEnd == nil {
+			return nil, fmt.Errorf("invalid DHCP range %q", value)
+		}
+		if secs, err := strconv.Atoi(c.getOption(OptionKeySharedDHCPLease)); err == nil {
+			config.LeaseTime = time.Duration(secs) * time.Second
+		}
+		return config, nil
+	}
+	return c.readDHCPDropIn()
+}
+
+// Restores NetworkManager default pool
+func (c *Connection) ClearDHCPServer() error {
+	if c.getOption(OptionKeySharedDHCPRange) != "" {
+		if err := c.setOptions(OptionKeySharedDHCPRange, "", OptionKeySharedDHCPLease, ""); err != nil {
+			return err
+		}
+	}
+	return c.removeDHCPDropIn()
+}
+
+func (c *Connection) validateDHCPServer(config DHCPServerConfig) error {
+	if method := c.getOption(OptionKeyIP4Method); method != ConnectionIP4MethodShared {
+		return fmt.Errorf("DHCP server requires %s %q, connection %q has %q",
+			OptionKeyIP4Method, ConnectionIP4MethodShared, c.Name, method)
+	}
+	start, end := config.RangeStart.To4(), config.RangeEnd.To4()
+	if start == nil || end == nil {
+		return fmt.Errorf("DHCP range must consist of IPv4 addresses")
+	}
+	if bytes.Compare(start, end) > 0 {
+		return fmt.Errorf("DHCP range start %s is after end %s", start, end)
+	}
+	if config.LeaseTime != 0 && config.LeaseTime < MinDHCPLeaseTime {
+		return fmt.Errorf("DHCP lease time must be at least %s", MinDHCPLeaseTime)
+	}
+
+	ip, network, err := c.sharedAddress()
+	if err != nil {
+		return err
+	}
+	if network == nil {
+		return nil
+	}
+	if !network.Contains(start) || !network.Contains(end) {
+		return fmt.Errorf("DHCP range %s-%s is outside of %s", start, end, network)
+	}
+	if bytes.Compare(start, ip) <= 0 && bytes.Compare(ip, end) <= 0 {
+		return fmt.Errorf("DHCP range %s-%s contains router address %s", start, end, ip)
+	}
+	return nil
+}
+
+// Returns router address and subnet of the shared connection, both are nil
+// when NetworkManager picks them itself
+func (c *Connection) sharedAddress() (net.IP, *net.IPNet, error) {
+	address, _, _ := strings.Cut(c.getOption(OptionKeyIP4Addresses), ",")
+	if address = strings.TrimSpace(address); address == "" {
+		// NetworkManager picks 10.42.x.1/24 for shared connections without address
+		return nil, nil, nil
+	}
+	ip, network, err := net.ParseCIDR(address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid connection address %q: %s", address, err)
+	}
+	return ip.To4(), network, nil
+}
+
+// Drop-in range can only add addresses to the default pool, so it must cover
+// the default pool to become the pool, otherwise unsupported is returned
+func (c *Connection) requireDropInCoversDefaultPool(config DHCPServerConfig, unsupported error) error {
+	ip, network, err := c.sharedAddress()
+	if err != nil {
+		return err
+	}
+	if network == nil {
+		return fmt.Errorf("default DHCP pool is unknown without address of connection %q: %w", c.Name, unsupported)
+	}
+	first, last := defaultDHCPPool(ip, network)
+	if first == nil {
+		return nil
+	}
+	if bytes.Compare(config.RangeStart.To4(), first) > 0 || bytes.Compare(config.RangeEnd.To4(), last) < 0 {
+		return fmt.Errorf("DHCP range %s-%s doesn't cover default pool %s-%s: %w",
+			config.RangeStart, config.RangeEnd, first, last, unsupported)
+	}
+	return nil
+}
+
+// Pool NetworkManager gives dnsmasq of a shared connection: addresses on the
+// larger side of the router address, at most 253 of them, with up to 8 ones
+// next to the router left for static addresses. Nil for too small subnets.
+func defaultDHCPPool(ip net.IP, network *net.IPNet) (net.IP, net.IP) {
+	ones, bits := network.Mask.Size()
+	if bits != 32 || ones > 30 {
+		return nil, nil
+	}
+	mask := int64(binary.BigEndian.Uint32(network.Mask))
+	host := int64(binary.BigEndian.Uint32(ip.To4()))
+	first := host&mask + 1
+	last := (host | ^mask&0xffffffff) - 1
+	if ones < 24 {
+		last = first + 254
+	}
+	if host-first > last-host {
+		reserved := min((host-first)/10, 8)
+		first, last = first+reserved, host-1
+	} else {
+		reserved := min((last-host)/10, 8)
+		first = host + 1 + reserved
+	}
+	return binary.BigEndian.AppendUint32(nil, uint32(first)), binary.BigEndian.AppendUint32(nil, uint32(last))
+}
+
+// dnsmasq uses a range only on interfaces with an address in its subnet, so
+// drop-ins of different connections don't interfere
+func (c *Connection) writeDHCPDropIn(config DHCPServerConfig) error {
+	lease := "1h"
+	if config.LeaseTime != 0 {
+		lease = strconv.Itoa(int(config.LeaseTime.Seconds()))
+	}
+	content := strings.Join([]string{
+		"# DHCP pool of connection " + strconv.Quote(c.Name),
+		fmt.Sprintf("dhcp-range=%s,%s,%s", config.RangeStart, config.RangeEnd, lease),
+		"",
+	}, "\n")
+	if err := os.MkdirAll(DnsmasqSharedDirectory, 0o755); err != nil {
+		return fmt.Errorf("failed create dnsmasq configuration directory: %s", err)
+	}
+	if err := os.WriteFile(c.dhcpDropIn(), []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed write dnsmasq configuration: %s", err)
+	}
+	return nil
+}
+
+func (c *Connection) readDHCPDropIn() (*DHCPServerConfig, error) {
+	data, err := os.ReadFile(c.dhcpDropIn())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed read dnsmasq configuration: %s", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(line), "dhcp-range=")
+		if !ok {
+			continue
+		}
+		fields := strings.Split(value, ",")
+		if len(fields) != 3 {
+			break
+		}
+		config := &DHCPServerConfig{RangeStart: net.ParseIP(fields[0]), RangeEnd: net.ParseIP(fields[1])}
+		if secs, err := strconv.Atoi(fields[2]); err == nil {
+			config.LeaseTime = time.Duration(secs) * time.Second
+		}
+		return config, nil
+	}
+	return nil, fmt.Errorf("invalid dnsmasq configuration %q", c.dhcpDropIn())
+}
+
+func (c *Connection) removeDHCPDropIn() error {
+	err := os.Remove(c.dhcpDropIn())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed remove dnsmasq configuration: %s", err)
+	}
+	return nil
+}
+
+func (c *Connection) dhcpDropIn() string {
+	return filepath.Join(DnsmasqSharedDirectory, dhcpDropInPrefix+c.UUID+".conf")
+}
@@ -0,0 +1,246 @@
+package nmcli
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	OptionKeyIP6DNS           = "ipv6.dns"
+	OptionKeyIP4DNSSearch     = "ipv4.dns-search"
+	OptionKeyIP6DNSSearch     = "ipv6.dns-search"
+	OptionKeyIP4DNSOptions    = "ipv4.dns-options"
+	OptionKeyIP6DNSOptions    = "ipv6.dns-options"
+	OptionKeyIP4DNSPriority   = "ipv4.dns-priority"
+	OptionKeyIP6DNSPriority   = "ipv6.dns-priority"
+	OptionKeyIP6IgnoreAutoDNS = "ipv6.ignore-auto-dns"
+	OptionKeyIP6Method        = "ipv6.method"
+)
+
+// IPv6 methods NetworkManager rejects DNS servers, domains and options with
+var ip6MethodsWithoutDNS = []string{"ignore", "disabled"}
+
+type IPFamily = string
+
+const (
+	IPFamily4 IPFamily = "ipv4"
+	IPFamily6 IPFamily = "ipv6"
+)
+
+// DNS settings of one IP family of a connection
+type DNSConfig struct {
+	Servers []net.IP `json:"servers"`
+	// Search domains, "~." routes all queries to servers of this connection
+	Search []string `json:"search"`
+	// resolv.conf options, e.g. "rotate" or "timeout:2"
+	Options []string `json:"options"`
+	// Don't use servers and domains received from DHCP or router advertisements
+	IgnoreAutoDNS bool `json:"ignoreAutoDns"`
+	// Lower value is preferred, zero is NetworkManager default. Negative value
+	// excludes configurations of other connections with greater priority.
+	Priority int `json:"priority"`
+}
+
+// Options NetworkManager passes to resolv.conf, ones followed by ':' take a number
+var dnsOptionNames = []string{
+	"ndots:", "timeout:", "attempts:",
+	"rotate", "no-check-names", "inet6", "ip6-bytestring", "ip6-dotint", "no-ip6-dotint",
+	"edns0", "single-request", "single-request-reopen", "no-tld-query", "use-vc",
+	"no-reload", "trust-ad", "no-aaaa",
+}
+
+func ValidateDNSOption(option string) error {
+	for _, name := range dnsOptionNames {
+		value, ok := strings.CutPrefix(option, name)
+		if !ok {
+			continue
+		}
+		if !strings.HasSuffix(name, ":") && value == "" {
+			return nil
+		}
+		if n, err := strconv.Atoi(value); strings.HasSuffix(name, ":") && err == nil && n >= 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid DNS option %q", option)
+}
+
+func (c *Connection) GetDNS(family IPFamily) DNSConfig {
+	priority, _ := strconv.Atoi(c.getOption(family + ".dns-priority"))
+	return DNSConfig{
+		Servers:       parseIPList(c.getOption(family + ".dns")),
+		Search:        splitList(c.getOption(family + ".dns-search")),
+		Options:       splitList(c.getOption(family + ".dns-options")),
+		IgnoreAutoDNS: c.getOption(family+".ignore-auto-dns") == TrueValue,
+		Priority:      priority,
+	}
+}
+
+// Replaces DNS settings of the IP family at once
+func (c *Connection) SetDNS(family IPFamily, config DNSConfig) error {
+	if family != IPFamily4 && family != IPFamily6 {
+		return fmt.Errorf("unknown IP family %q", family)
+	}
+	for _, server := range config.Servers {
+		if (server.To4() != nil) != (family == IPFamily4) {
+			return fmt.Errorf("DNS server %s doesn't belong to %s", server, family)
+		}
+	}
+	for _, option := range config.Options {
+		if err := ValidateDNSOption(option); err != nil {
+			return err
+		}
+	}
+	return c.setOptions(
+		family+".dns", joinIPList(config.Servers),
+		family+".dns-search", strings.Join(config.Search, ","),
+		family+".dns-options", strings.Join(config.Options, ","),
+		family+".ignore-auto-dns", formatBool(config.IgnoreAutoDNS),
+		family+".dns-priority", strconv.Itoa(config.Priority),
+	)
+}
+
+// Returns DNS servers of both IP families
+func (c *Connection) GetDNSServers() []net.IP {
+	return append(c.GetDNS(IPFamily4).Servers, c.GetDNS(IPFamily6).Servers...)
+}
+
+// Sets DNS servers, IPv4 and IPv6 ones go to settings of their family
+func (c *Connection) SetDNSServers(servers []net.IP) error {
+	ip4, ip6 := []net.IP{}, []net.IP{}
+	for _, server := range servers {
+		if server.To4() != nil {
+			ip4 = append(ip4, server)
+		} else {
+			ip6 = append(ip6, server)
+		}
+	}
+	return c.setOptions(OptionKeyDNSAddresses, joinIPList(ip4), OptionKeyIP6DNS, joinIPList(ip6))
+}
+
+// Sets search domains of both IP families, IPv6 ones only if IPv6 is enabled
+func (c *Connection) SetDNSSearch(domains []string) error {
+	return c.setOptions(c.dnsParams(OptionKeyIP4DNSSearch, OptionKeyIP6DNSSearch, strings.Join(domains, ","))...)
+}
+
+// Returns params setting IPv4 option and its IPv6 counterpart unless IPv6 is
+// ignored or disabled
+func (c *Connection) dnsParams(ip4Option, ip6Option, value string) []string {
+	params := []string{ip4Option, value}
+	if !slices.Contains(ip6MethodsWithoutDNS, c.getOption(OptionKeyIP6Method)) {
+		params = append(params, ip6Option, value)
+	}
+	return params
+}
+
+func (c *Connection) SetIgnoreAutoDNS(ignore bool) error {
+	return c.setOptions(
+		OptionKeyIP4IgnoreAutoDNS, formatBool(ignore),
+		OptionKeyIP6IgnoreAutoDNS, formatBool(ignore),
+	)
+}
+
+func (c *Connection) SetDNSPriority(priority int) error {
+	return c.setOptions(
+		OptionKeyIP4DNSPriority, strconv.Itoa(priority),
+		OptionKeyIP6DNSPriority, strconv.Itoa(priority),
+	)
+}
+
+func (c *Connection) SetDNSOptions(options []string) error {
+	for _, option := range options {
+		if err := ValidateDNSOption(option); err != nil {
+			return err
+		}
+	}
+	return c.setOptions(c.dnsParams(OptionKeyIP4DNSOptions, OptionKeyIP6DNSOptions, strings.Join(options, ","))...)
+}
+
+// Resolver configuration NetworkManager produced for a device
+type ResolverConfig struct {
+	Device     string   `json:"device"`
+	Connection string   `json:"connection"`
+	Servers    []net.IP `json:"servers"`
+	Domains    []string `json:"domains"`
+}
+
+func GetResolverConfig(device string) (*ResolverConfig, error) {
+	dev, err := GetDevice(device)
+	if err != nil {
+		return nil, err
+	}
+	config := dev.ResolverConfig()
+	config.Device = device
+	return &config, nil
+}
+
+// Returns resolver configurations of devices which have any
+func GetResolverConfigs() ([]ResolverConfig, error) {
+	devices, err := currentBackend.listDevices()
+	if err != nil {
+		return nil, err
+	}
+	configs := []ResolverConfig{}
+	for _, device := range devices {
+		config, err := GetResolverConfig(device)
+		if err != nil {
+			log.Warn("Failed get resolver configuration", "device", device, "error", err)
+			continue
+		}
+		if len(config.Servers) > 0 || len(config.Domains) > 0 {
+			configs = append(configs, *config)
+		}
+	}
+	return configs, nil
+}
+
+func (d *Device) ResolverConfig() ResolverConfig {
+	config := ResolverConfig{
+		Device:     d.getOption("GENERAL.DEVICE"),
+		Connection: d.getOption("GENERAL.CONNECTION"),
+		Servers:    []net.IP{},
+		Domains:    []string{},
+	}
+	for _, family := range []string{"IP4", "IP6"} {
+		for _, server := range d.indexedOptions(family + ".DNS") {
+			if ip := net.ParseIP(server); ip != nil {
+				config.Servers = append(config.Servers, ip)
+			}
+		}
+		config.Domains = append(config.Domains, d.indexedOptions(family+".DOMAIN")...)
+	}
+	return config
+}
+
+// Returns values of options numbered like IP4.DNS[1], IP4.DNS[2]
+func (d *Device) indexedOptions(name string) []string {
+	values := []string{}
+	for i := 1; ; i++ {
+		value := d.getOption(fmt.Sprintf("%s[%d]", name, i))
+		if value == "" {
+			return values
+		}
+		values = append(values, value)
+	}
+}
+
+func parseIPList(value string) []net.IP {
+	ips := []net.IP{}
+	for _, item := range splitList(value) {
+		if ip := net.ParseIP(item); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+func joinIPList(ips []net.IP) string {
+	items := []string{}
+	for _, ip := range ips {
+		items = append(items, ip.String())
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,201 @@
+package nmcli
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Desired state of an access point profile
+type AccessPointSpec struct {
+	// Profile is looked up by UUID if it is set and by name otherwise
+	Name     string
+	UUID     string
+	Device   string
+	SSID     string
+	Security AccessPointSecurity
+	// Empty band lets NetworkManager choose
+	Band WirelessBand
+	// Zero channel lets NetworkManager choose
+	Channel int
+	Hidden  bool
+	// Bridge device to attach the access point to instead of sharing its own subnet
+	Bridge string
+}
+
+// What EnsureAccessPoint had to do to bring profile to the desired state
+type AccessPointChanges struct {
+	Connection *WirelessConnection `json:"-"`
+	Created    bool                `json:"created"`
+	// Options which were updated on existing profile
+	Changed   []string `json:"changed"`
+	Activated bool     `json:"activated"`
+}
+
+func (c *AccessPointChanges) HasChanges() bool {
+	return c.Created || c.Activated || len(c.Changed) > 0
+}
+
+// Makes sure access point profile matching spec exists and is active. Profile
+// is created only when missing, only options which differ are updated, so
+// calling it repeatedly with the same spec changes nothing.
+func EnsureAccessPoint(spec AccessPointSpec) (*AccessPointChanges, error) {
+	return ensureAccessPoint(spec, true)
+}
+
+// Reports what EnsureAccessPoint would change without changing anything
+func PlanAccessPoint(spec AccessPointSpec) (*AccessPointChanges, error) {
+	return ensureAccessPoint(spec, false)
+}
+
+func ensureAccessPoint(spec AccessPointSpec, apply bool) (*AccessPointChanges, error) {
+	if spec.Name == "" && spec.UUID == "" {
+		return nil, fmt.Errorf("access point name or UUID is required")
+	}
+	if err := ValidateSSID(spec.SSID); err != nil {
+		return nil, err
+	}
+	securityParams, err := spec.Security.params()
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := findConnection(spec.Name, spec.UUID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		// Profile can't be created without a name
+		if spec.Name == "" {
+			return nil, fmt.Errorf("%w %q", ErrConnectionNotFound, spec.UUID)
+		}
+		if !apply {
+			return &AccessPointChanges{Created: true, Activated: true}, nil
+		}
+		conn, err := createAccessPoint(spec.Device, spec.Name, spec.SSID, append(spec.radioParams(), securityParams...))
+		if err != nil {
+			return nil, err
+		}
+		return &AccessPointChanges{Connection: conn, Created: true, Activated: true}, nil
+	}
+
+	conn, err := GetConnection(existing.UUID)
+	if err != nil {
+		return nil, err
+	}
+	wireless, err := conn.AsWireless()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &AccessPointChanges{Connection: wireless}
+	params := wireless.diff(spec, securityParams)
+	for i := 0; i < len(params); i += 2 {
+		if params[i] == removeSettingParam {
+			changes.Changed = append(changes.Changed, params[i+1])
+			continue
+		}
+		changes.Changed = append(changes.Changed, params[i])
+	}
+	// Changes are applied to the running access point on reactivation
+	changes.Activated = len(params) > 0 || !wireless.IsActive()
+	if !apply {
+		return changes, nil
+	}
+
+	if len(params) > 0 {
+		if err := wireless.setOptions(params...); err != nil {
+			return nil, err
+		}
+	}
+	if changes.Activated {
+		if err := wireless.Up(); err != nil {
+			return nil, fmt.Errorf("can't start access point: %w", err)
+		}
+	}
+	return changes, nil
+}
+
+// Returns connection with UUID if it is set or with name, nil if none exists
+func findConnection(name, uuid string) (*Connection, error) {
+	connections, err := GetConnections()
+	if err != nil {
+		return nil, err
+	}
+	var found *Connection
+	for _, conn := range connections {
+		if (uuid != "" && conn.UUID == uuid) || (uuid == "" && conn.Name == name) {
+			if found != nil {
+				log.Warn("Several connections match, using the first one", "name", name, "uuid", found.UUID)
+				break
+			}
+			found = &conn
+		}
+	}
+	return found, nil
+}
+
+func (s *AccessPointSpec) radioParams() []string {
+	params := []string{
+		OptionKeyWirelessBand, s.Band,
+		OptionKeyWirelessChanel, strconv.Itoa(s.Channel),
+		OptionKeyWirelessHidden, hiddenValue(s.Hidden),
+	}
+	if s.Bridge != "" {
+		params = append(params, OptionKeyMaster, s.Bridge, OptionKeySlaveType, string(ConnectionTypeBridge))
+	}
+	return params
+}
+
+func hiddenValue(hidden bool) string {
+	if hidden {
+		return WirelessHiddenValue
+	}
+	return WirelessNotHiddenValue
+}
+
+// Returns params updating options of the connection which differ from spec
+func (c *WirelessConnection) diff(spec AccessPointSpec, securityParams []string) []string {
+	params := []string{}
+	if c.Device != spec.Device && spec.Device != "" {
+		params = append(params, OptionKeyInterfaceName, spec.Device)
+	}
+	if c.GetMode() != WirelessModeAccessPoint {
+		params = append(params, OptionKeyWirelessMode, string(WirelessModeAccessPoint))
+	}
+	if c.GetSSID() != spec.SSID {
+		params = append(params, OptionKeyWirelessSSID, spec.SSID)
+	}
+	if c.GetBand() != spec.Band {
+		params = append(params, OptionKeyWirelessBand, spec.Band)
+	}
+	if c.GetChanel() != spec.Channel {
+		params = append(params, OptionKeyWirelessChanel, strconv.Itoa(spec.Channel))
+	}
+	if c.IsHidden() != spec.Hidden {
+		params = append(params, OptionKeyWirelessHidden, hiddenValue(spec.Hidden))
+	}
+	if c.getOption(OptionKeyMaster) != spec.Bridge {
+		slaveType := ""
+		if spec.Bridge != "" {
+			slaveType = string(ConnectionTypeBridge)
+		}
+		params = append(params, OptionKeyMaster, spec.Bridge, OptionKeySlaveType, slaveType)
+	}
+
+	if spec.Security.Profile == SecurityProfileOpen {
+		if c.GetSecurityProfile() != SecurityProfileOpen {
+			params = append(params, removeSettingParam, SettingWirelessSecurity)
+		}
+		return params
+	}
+	for i := 0; i < len(securityParams); i += 2 {
+		current := c.getOption(securityParams[i])
+		if securityParams[i] == OptionKeyWirelessSecurityPMF {
+			current = c.GetPMF()
+		}
+		if current != securityParams[i+1] {
+			params = append(params, securityParams[i], securityParams[i+1])
+		}
+	}
+	return params
+}
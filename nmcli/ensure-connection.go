@@ -0,0 +1,135 @@
+package nmcli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Desired state of a connection profile of any type
+type ConnectionSpec struct {
+	Name   string
+	Type   ConnectionType
+	Device string
+	// Option name and value pairs in nmcli format, empty value means unset
+	Options []string
+}
+
+// What EnsureConnection did or would do to bring profile to the desired state
+type ConnectionChanges struct {
+	Connection *Connection `json:"-"`
+	Created    bool        `json:"created"`
+	// Options which were updated on existing profile
+	Changed   []string `json:"changed"`
+	Activated bool     `json:"activated"`
+}
+
+func (c *ConnectionChanges) HasChanges() bool {
+	return c.Created || c.Activated || len(c.Changed) > 0
+}
+
+// Reports what EnsureConnection would change without changing anything
+func PlanConnection(spec ConnectionSpec) (*ConnectionChanges, error) {
+	return ensureConnection(spec, false)
+}
+
+// Makes sure profile matching spec exists and is active. Profile is looked up
+// by name and created only when missing, only options which differ are updated.
+func EnsureConnection(spec ConnectionSpec) (*ConnectionChanges, error) {
+	return ensureConnection(spec, true)
+}
+
+func ensureConnection(spec ConnectionSpec, apply bool) (*ConnectionChanges, error) {
+	if spec.Name == "" {
+		return nil, fmt.Errorf("connection name is required")
+	}
+	if len(spec.Options)%2 != 0 {
+		return nil, fmt.Errorf("odd number of connection options")
+	}
+
+	existing, err := findConnection(spec.Name, "")
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		changes := &ConnectionChanges{Created: true, Activated: true}
+		if !apply {
+			return changes, nil
+		}
+		params := []string{}
+		for i := 0; i < len(spec.Options); i += 2 {
+			if spec.Options[i+1] != "" {
+				params = append(params, spec.Options[i], spec.Options[i+1])
+			}
+		}
+		conn, err := createConnection(spec.Type, spec.Device, spec.Name, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed create connection %q: %w", spec.Name, err)
+		}
+		changes.Connection = conn
+		if err := conn.Up(); err != nil {
+			return nil, fmt.Errorf("failed activate connection %q: %w", spec.Name, err)
+		}
+		return changes, nil
+	}
+
+	conn, err := GetConnection(existing.UUID)
+	if err != nil {
+		return nil, err
+	}
+	if conn.Type != ConnectionType(connectionTypeAliases.resolve(string(spec.Type))) {
+		return nil, fmt.Errorf("connection %q exists with type %s, not %s", spec.Name, conn.Type, spec.Type)
+	}
+
+	changes := &ConnectionChanges{Connection: conn}
+	params := conn.diff(spec)
+	for i := 0; i < len(params); i += 2 {
+		changes.Changed = append(changes.Changed, params[i])
+	}
+	changes.Activated = len(params) > 0 || !conn.IsActive()
+	if !apply {
+		return changes, nil
+	}
+
+	if len(params) > 0 {
+		if err := conn.setOptions(params...); err != nil {
+			return nil, err
+		}
+	}
+	// Changes are applied to the running connection on reactivation
+	if changes.Activated {
+		if err := conn.Up(); err != nil {
+			return nil, fmt.Errorf("failed activate connection %q: %w", spec.Name, err)
+		}
+	}
+	return changes, nil
+}
+
+// Returns params updating options of the connection which differ from spec
+func (c *Connection) diff(spec ConnectionSpec) []string {
+	params := []string{}
+	if spec.Device != "" && c.getOption(OptionKeyInterfaceName) != spec.Device {
+		params = append(params, OptionKeyInterfaceName, spec.Device)
+	}
+	for i := 0; i < len(spec.Options); i += 2 {
+		option := optionKeyAliases.resolve(spec.Options[i])
+		if !optionValuesEqual(c.getOption(option), spec.Options[i+1]) {
+			params = append(params, option, spec.Options[i+1])
+		}
+	}
+	return params
+}
+
+// Compares option values ignoring differences in formatting of booleans and
+// lists, e.g. "yes" equals "true" and "a, b" equals "a,b"
+func optionValuesEqual(current, desired string) bool {
+	if current == desired {
+		return true
+	}
+	currentBool, currentErr := optionToValue(dbusOptionBool, current)
+	desiredBool, desiredErr := optionToValue(dbusOptionBool, desired)
+	if currentErr == nil && desiredErr == nil {
+		return currentBool == desiredBool
+	}
+	return slices.Equal(splitList(strings.TrimSpace(current)), splitList(strings.TrimSpace(desired)))
+}
@@ -0,0 +1,121 @@
+package nmcli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zarinit-routers/cli"
+)
+
+// Failures reported by NetworkManager, match them with errors.Is. Backends
+// other than nmcli wrap the same errors where they can tell the reason.
+var (
+	ErrInvalidInput        = errors.New("invalid input")
+	ErrTimeout             = errors.New("timeout expired")
+	ErrActivationFailed    = errors.New("connection activation failed")
+	ErrDeactivationFailed  = errors.New("connection deactivation failed")
+	ErrDisconnectFailed    = errors.New("device disconnect failed")
+	ErrDeletionFailed      = errors.New("connection deletion failed")
+	ErrNMNotRunning        = errors.New("NetworkManager is not running")
+	ErrConnectionNotFound  = errors.New("no such connection profile")
+	ErrDeviceNotFound      = errors.New("no such device")
+	ErrAccessPointNotFound = errors.New("no such access point")
+)
+
+// Exit codes documented in nmcli(1)
+const (
+	exitCodeUnknown            = 1
+	exitCodeInvalidInput       = 2
+	exitCodeTimeout            = 3
+	exitCodeActivationFailed   = 4
+	exitCodeDeactivationFailed = 5
+	exitCodeDisconnectFailed   = 6
+	exitCodeDeletionFailed     = 7
+	exitCodeNotRunning         = 8
+	exitCodeNotFound           = 10
+)
+
+var exitCodeErrors = map[int]error{
+	exitCodeInvalidInput:       ErrInvalidInput,
+	exitCodeTimeout:            ErrTimeout,
+	exitCodeActivationFailed:   ErrActivationFailed,
+	exitCodeDeactivationFailed: ErrDeactivationFailed,
+	exitCodeDisconnectFailed:   ErrDisconnectFailed,
+	exitCodeDeletionFailed:     ErrDeletionFailed,
+	exitCodeNotRunning:         ErrNMNotRunning,
+}
+
+// Failed nmcli command, keeps exit code and message for callers to inspect
+type CommandError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// Sentinel error the failure maps to, nil if reason is unknown
+	err error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("nmcli failed with code %d: %s", e.Code, e.Message)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.err
+}
+
+// Runs nmcli, failures are returned as [CommandError]
+func runNmcli(args ...string) ([]byte, error) {
+	output, code, err := cli.ExecuteWithCode("nmcli", args...)
+	if err != nil {
+		message := cleanOutput(output)
+		return nil, &CommandError{Code: code, Message: message, err: classifyFailure(code, message, args)}
+	}
+	return output, nil
+}
+
+func runNmcliErr(args ...string) error {
+	_, err := runNmcli(args...)
+	return err
+}
+
+// Stderr is checked first, because nmcli uses generic codes for some failures,
+// e.g. 10 for both missing connections and devices
+func classifyFailure(code int, message string, args []string) error {
+	message = strings.ToLower(message)
+	switch {
+	case strings.Contains(message, "networkmanager is not running"):
+		return ErrNMNotRunning
+	case strings.Contains(message, "unknown connection"),
+		strings.Contains(message, "no such connection profile"):
+		return ErrConnectionNotFound
+	case strings.Contains(message, "no network with ssid"),
+		strings.Contains(message, "no access point"):
+		return ErrAccessPointNotFound
+	case strings.HasPrefix(message, "error: device") && strings.Contains(message, "not found"):
+		return ErrDeviceNotFound
+	}
+
+	if code == exitCodeNotFound {
+		if nmcliObject(args) == "device" {
+			return ErrDeviceNotFound
+		}
+		return ErrConnectionNotFound
+	}
+	return exitCodeErrors[code]
+}
+
+// Returns object nmcli command works with, e.g. "connection" or "device"
+func nmcliObject(args []string) string {
+	for _, arg := range args {
+		if arg == "" || strings.HasPrefix(arg, "-") {
+			continue
+		}
+		switch arg {
+		case "c", "con", "connection":
+			return "connection"
+		case "d", "dev", "device":
+			return "device"
+		}
+		return arg
+	}
+	return ""
+}
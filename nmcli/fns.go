@@ -2,8 +2,6 @@ package nmcli
 
 import (
 	"strings"
-
-	"github.com/zarinit-routers/cli"
 )
 
 const (
@@ -11,11 +9,11 @@ const (
 )
 
 func GetHardwareAddress(deviceName string) (address string, err error) {
-	output, err := cli.Execute("nmcli", terseFlag, getFieldsFlag(OptionKeyHardwareAddress), "device", "show", deviceName)
+	dev, err := GetDevice(deviceName)
 	if err != nil {
 		return "", err
 	}
-	return cleanOutput(output), nil
+	return dev.getOption(OptionKeyHardwareAddress), nil
 }
 
 func cleanOutput(output []byte) string {
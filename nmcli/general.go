@@ -0,0 +1,196 @@
+// Global NetworkManager state and radio switches. These always use nmcli,
+// regardless of the selected backend.
+package nmcli
+
+import (
+	"fmt"
+	"strings"
+)
+
+type NetworkManagerState = string
+
+const (
+	StateAsleep            NetworkManagerState = "asleep"
+	StateDisconnected      NetworkManagerState = "disconnected"
+	StateConnecting        NetworkManagerState = "connecting"
+	StateConnectedLocal    NetworkManagerState = "connected (local only)"
+	StateConnectedSite     NetworkManagerState = "connected (site only)"
+	StateConnectedGlobal   NetworkManagerState = "connected"
+	StateDisconnecting     NetworkManagerState = "disconnecting"
+	StateNetworkingOffline NetworkManagerState = "offline"
+)
+
+// Overall NetworkManager status as shown by `nmcli general status`
+type GeneralStatus struct {
+	Running      bool                `json:"running"`
+	Version      string              `json:"version"`
+	State        NetworkManagerState `json:"state"`
+	Startup      string              `json:"startup"`
+	Connectivity Connectivity        `json:"connectivity"`
+	Networking   bool                `json:"networking"`
+	Radio        RadioState          `json:"radio"`
+}
+
+// Software switches can be toggled, hardware ones are rfkill switches
+type RadioState struct {
+	WifiHardware bool `json:"wifiHardware"`
+	Wifi         bool `json:"wifi"`
+	WWANHardware bool `json:"wwanHardware"`
+	WWAN         bool `json:"wwan"`
+}
+
+const radioEnabledValue = "enabled"
+
+var generalStatusFields = []string{
+	"RUNNING", "VERSION", "STATE", "STARTUP", "CONNECTIVITY", "NETWORKING", "WIFI-HW", "WIFI", "WWAN-HW", "WWAN",
+}
+
+func GetGeneralStatus() (*GeneralStatus, error) {
+	output, err := runNmcli(terseFlag, "--fields="+strings.Join(generalStatusFields, ","), "general", "status")
+	if err != nil {
+		return nil, fmt.Errorf("failed get NetworkManager status: %w", err)
+	}
+	fields := splitTerseLine(strings.TrimSpace(string(output)))
+	if len(fields) != len(generalStatusFields) {
+		return nil, fmt.Errorf("unexpected NetworkManager status %q", strings.TrimSpace(string(output)))
+	}
+	return &GeneralStatus{
+		Running:      fields[0] == "running",
+		Version:      fields[1],
+		State:        fields[2],
+		Startup:      fields[3],
+		Connectivity: fields[4],
+		Networking:   fields[5] == radioEnabledValue,
+		Radio: RadioState{
+			WifiHardware: fields[6] == radioEnabledValue,
+			Wifi:         fields[7] == radioEnabledValue,
+			WWANHardware: fields[8] == radioEnabledValue,
+			WWAN:         fields[9] == radioEnabledValue,
+		},
+	}, nil
+}
+
+// Returns connectivity NetworkManager detected last time, check makes it
+// probe connectivity again and wait for the result
+func GetConnectivity(check bool) (Connectivity, error) {
+	args := []string{terseFlag, "networking", "connectivity"}
+	if check {
+		args = append(args, "check")
+	}
+	output, err := runNmcli(args...)
+	if err != nil {
+		return ConnectivityUnknown, fmt.Errorf("failed get connectivity: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Returns permissions of the caller, e.g.
+// "org.freedesktop.NetworkManager.network-control" to "yes", "no" or "auth"
+func GetPermissions() (map[string]string, error) {
+	output, err := runNmcli(terseFlag, "general", "permissions")
+	if err != nil {
+		return nil, fmt.Errorf("failed get permissions: %w", err)
+	}
+	permissions := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := splitTerseLine(line)
+		if len(fields) == 2 {
+			permissions[fields[0]] = fields[1]
+		}
+	}
+	return permissions, nil
+}
+
+func GetHostname() (string, error) {
+	output, err := runNmcli("general", "hostname")
+	if err != nil {
+		return "", fmt.Errorf("failed get hostname: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Sets persistent hostname of the system
+func SetHostname(hostname string) error {
+	if hostname == "" || len(hostname) > 64 {
+		return fmt.Errorf("invalid hostname %q", hostname)
+	}
+	return runNmcliErr("general", "hostname", hostname)
+}
+
+type LogLevel = string
+
+const (
+	LogLevelOff   LogLevel = "OFF"
+	LogLevelErr   LogLevel = "ERR"
+	LogLevelWarn  LogLevel = "WARN"
+	LogLevelInfo  LogLevel = "INFO"
+	LogLevelDebug LogLevel = "DEBUG"
+	LogLevelTrace LogLevel = "TRACE"
+)
+
+// Returns NetworkManager log level and logging domains
+func GetLogging() (LogLevel, []string, error) {
+	output, err := runNmcli(terseFlag, "general", "logging")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed get logging configuration: %w", err)
+	}
+	fields := splitTerseLine(strings.TrimSpace(string(output)))
+	if len(fields) != 2 {
+		return "", nil, fmt.Errorf("unexpected logging configuration %q", strings.TrimSpace(string(output)))
+	}
+	return fields[0], splitList(fields[1]), nil
+}
+
+// Changes NetworkManager logging until restart, no domains means all of them
+func SetLogging(level LogLevel, domains ...string) error {
+	args := []string{"general", "logging", "level", strings.ToUpper(level)}
+	if len(domains) > 0 {
+		args = append(args, "domains", strings.Join(domains, ","))
+	}
+	return runNmcliErr(args...)
+}
+
+func IsNetworkingEnabled() (bool, error) {
+	output, err := runNmcli(terseFlag, "networking")
+	if err != nil {
+		return false, fmt.Errorf("failed get networking state: %w", err)
+	}
+	return strings.TrimSpace(string(output)) == radioEnabledValue, nil
+}
+
+// Disabling networking deactivates all connections until it is enabled again
+func SetNetworking(enabled bool) error {
+	return runNmcliErr("networking", onOff(enabled))
+}
+
+func GetRadioState() (*RadioState, error) {
+	output, err := runNmcli(terseFlag, "--fields=WIFI-HW,WIFI,WWAN-HW,WWAN", "radio")
+	if err != nil {
+		return nil, fmt.Errorf("failed get radio state: %w", err)
+	}
+	fields := splitTerseLine(strings.TrimSpace(string(output)))
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("unexpected radio state %q", strings.TrimSpace(string(output)))
+	}
+	return &RadioState{
+		WifiHardware: fields[0] == radioEnabledValue,
+		Wifi:         fields[1] == radioEnabledValue,
+		WWANHardware: fields[2] == radioEnabledValue,
+		WWAN:         fields[3] == radioEnabledValue,
+	}, nil
+}
+
+func SetWifiRadio(enabled bool) error {
+	return runNmcliErr("radio", "wifi", onOff(enabled))
+}
+
+func SetWWANRadio(enabled bool) error {
+	return runNmcliErr("radio", "wwan", onOff(enabled))
+}
+
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
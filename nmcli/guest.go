@@ -0,0 +1,238 @@
+package nmcli
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zarinit-routers/cli/nft"
+)
+
+const OptionKeyWirelessAPIsolation = "802-11-wireless.ap-isolation"
+
+const guestFirewallScriptPrefix = "90-guest-"
+
+var (
+	DefaultGuestAdminPorts = []int{22, 80, 443}
+	// Private ranges guests are not allowed to reach, unless LAN subnets are specified
+	DefaultGuestDeniedSubnets = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+)
+
+type GuestNetworkSpec struct {
+	Device   string
+	SSID     string
+	Security AccessPointSecurity
+	// Router address with prefix, e.g. 192.168.50.1/24
+	Address string
+	// Subnets guests must not reach, defaults to [DefaultGuestDeniedSubnets]
+	LANSubnets []string
+	// Router ports closed for guests, defaults to [DefaultGuestAdminPorts]
+	AdminPorts []int
+}
+
+// Access point with its own shared subnet, isolated clients and firewall
+// rules keeping guests away from LAN and router administration.
+type GuestNetwork struct {
+	Name       string
+	Spec       GuestNetworkSpec
+	Connection *WirelessConnection
+}
+
+// Creates and enables guest network, name is used for the connection profile
+func CreateGuestNetwork(name string, spec GuestNetworkSpec) (*GuestNetwork, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	securityParams, err := spec.Security.params()
+	if err != nil {
+		return nil, err
+	}
+
+	params := []string{
+		"autoconnect", TrueValue,
+		"ssid", spec.SSID,
+		OptionKeyWirelessMode, string(WirelessModeAccessPoint),
+		OptionKeyWirelessAPIsolation, TrueValue,
+		OptionKeyIP4Method, ConnectionIP4MethodShared,
+		OptionKeyIP4Addresses, spec.Address,
+	}
+	conn, err := createConnection(ConnectionTypeWIFI, spec.Device, name, append(params, securityParams...))
+	if err != nil {
+		return nil, fmt.Errorf("failed create guest connection: %w", err)
+	}
+
+	guest := &GuestNetwork{Name: name, Spec: spec, Connection: &WirelessConnection{conn}}
+	if err := guest.Enable(); err != nil {
+		if teardownErr := guest.Teardown(); teardownErr != nil {
+			log.Warn("Failed clean up guest network", "name", name, "error", teardownErr)
+		}
+		return nil, err
+	}
+	return guest, nil
+}
+
+// Looks up guest network created earlier, spec is restored from the connection.
+// Firewall options are not stored in the profile, custom ones must be set on
+// Spec again before calling Enable.
+func GetGuestNetwork(name string) (*GuestNetwork, error) {
+	conn, err := GetConnection(name)
+	if err != nil {
+		return nil, err
+	}
+	wireless, err := conn.AsWireless()
+	if err != nil {
+		return nil, err
+	}
+	return &GuestNetwork{
+		Name:       name,
+		Connection: wireless,
+		Spec: GuestNetworkSpec{
+			Device:  conn.Device,
+			SSID:    wireless.GetSSID(),
+			Address: conn.getOption(OptionKeyIP4Addresses),
+		},
+	}, nil
+}
+
+func (s *GuestNetworkSpec) validate() error {
+	if s.Device == "" {
+		return fmt.Errorf("device is required for guest network")
+	}
+	if err := ValidateSSID(s.SSID); err != nil {
+		return err
+	}
+	if _, _, err := net.ParseCIDR(s.Address); err != nil {
+		return fmt.Errorf("invalid guest address %q: %s", s.Address, err)
+	}
+	for _, subnet := range s.LANSubnets {
+		if _, _, err := net.ParseCIDR(subnet); err != nil {
+			return fmt.Errorf("invalid LAN subnet %q: %s", subnet, err)
+		}
+	}
+	for _, port := range s.AdminPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid admin port %d", port)
+		}
+	}
+	return nil
+}
+
+// Activates the access point and installs firewall rules. Rules are restored
+// by a dispatcher script each time the access point comes up, until teardown.
+func (g *GuestNetwork) Enable() error {
+	if err := g.writeFirewallScript(); err != nil {
+		return err
+	}
+	if err := g.Connection.Up(); err != nil {
+		return fmt.Errorf("failed activate guest network %q: %w", g.Name, err)
+	}
+	if err := nft.ReplaceTable(nft.FamilyInet, g.firewallTable(), g.firewallRules()); err != nil {
+		return fmt.Errorf("failed install guest firewall: %s", err)
+	}
+	return nil
+}
+
+// Deactivates the access point and removes firewall rules. Dispatcher script
+// is kept, so rules come back if the access point is activated again.
+func (g *GuestNetwork) Disable() error {
+	if g.Connection.IsActive() {
+		if err := g.Connection.Down(); err != nil {
+			return fmt.Errorf("failed deactivate guest network %q: %w", g.Name, err)
+		}
+	}
+	return nft.DeleteTable(nft.FamilyInet, g.firewallTable())
+}
+
+// Removes the connection profile, firewall rules and dispatcher script of
+// the guest network
+func (g *GuestNetwork) Teardown() error {
+	if err := nft.DeleteTable(nft.FamilyInet, g.firewallTable()); err != nil {
+		return err
+	}
+	if err := g.removeFirewallScript(); err != nil {
+		return err
+	}
+	if err := g.Connection.Delete(); err != nil {
+		return fmt.Errorf("failed delete guest connection %q: %w", g.Name, err)
+	}
+	return nil
+}
+
+// Writes dispatcher script loading firewall rules when the access point comes
+// up and deleting them when it goes down
+func (g *GuestNetwork) writeFirewallScript() error {
+	table := g.firewallTable()
+	script := strings.Join([]string{
+		"#!/bin/sh",
+		"# Restores firewall of guest network " + strconv.Quote(g.Name),
+		fmt.Sprintf(`[ "$CONNECTION_UUID" = %s ] || exit 0`, g.Connection.UUID),
+		`case "$2" in`,
+		"up)",
+		"\texec nft -f - <<'EOF'",
+		nft.ReplaceTableRuleset(nft.FamilyInet, table, g.firewallRules()) + "EOF",
+		"\t;;",
+		"down)",
+		fmt.Sprintf("\texec nft delete table %s %s 2>/dev/null", nft.FamilyInet, table),
+		"\t;;",
+		"esac",
+		"",
+	}, "\n")
+	// NetworkManager ignores scripts writable by anyone but owner
+	if err := os.WriteFile(g.firewallScript(), []byte(script), 0o755); err != nil {
+		return fmt.Errorf("failed persist guest firewall: %s", err)
+	}
+	return nil
+}
+
+func (g *GuestNetwork) removeFirewallScript() error {
+	err := os.Remove(g.firewallScript())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed remove guest firewall script: %s", err)
+	}
+	return nil
+}
+
+func (g *GuestNetwork) firewallScript() string {
+	return filepath.Join(DispatcherDirectory, guestFirewallScriptPrefix+g.Connection.UUID)
+}
+
+var nftNameUnsafeRegex = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func (g *GuestNetwork) firewallTable() string {
+	return "guest_" + nftNameUnsafeRegex.ReplaceAllString(g.Name, "_")
+}
+
+func (g *GuestNetwork) firewallRules() string {
+	iface := strconv.Quote(g.Spec.Device)
+	denied := g.Spec.LANSubnets
+	if len(denied) == 0 {
+		denied = DefaultGuestDeniedSubnets
+	}
+	ports := g.Spec.AdminPorts
+	if len(ports) == 0 {
+		ports = DefaultGuestAdminPorts
+	}
+	portStrings := []string{}
+	for _, port := range ports {
+		portStrings = append(portStrings, strconv.Itoa(port))
+	}
+
+	rules := []string{
+		"chain input {",
+		"type filter hook input priority filter - 10; policy accept;",
+		fmt.Sprintf("iifname %s tcp dport { %s } drop", iface, strings.Join(portStrings, ", ")),
+		"}",
+		"chain forward {",
+		"type filter hook forward priority filter - 10; policy accept;",
+		fmt.Sprintf("iifname %s ip daddr { %s } drop", iface, strings.Join(denied, ", ")),
+		// LAN clients must not open connections to guests either
+		fmt.Sprintf("oifname %s ct state new iifname != %s drop", iface, iface),
+		"}",
+	}
+	return strings.Join(rules, "\n")
+}
@@ -0,0 +1,480 @@
+package nmcli
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zarinit-routers/cli/keyfile"
+)
+
+// Directory NetworkManager stores connection profiles in
+const KeyfileDirectory = "/etc/NetworkManager/system-connections"
+
+const keyfileExtension = ".nmconnection"
+
+var ErrNetworkManagerOffline = errors.New("operation requires running NetworkManager")
+
+// Read and write connection profiles as keyfiles in dir, which is
+// [KeyfileDirectory] if empty. Works while NetworkManager is not running, so
+// profiles can be prepared or repaired, but nothing can be activated.
+// NetworkManager picks changes up on start or `nmcli connection reload`.
+// Target is version of NetworkManager which will load the profiles, options
+// it doesn't support are rejected.
+func UseKeyfileBackend(dir string, target Version) error {
+	if dir == "" {
+		dir = KeyfileDirectory
+	}
+	if target == (Version{}) {
+		return fmt.Errorf("target NetworkManager version is required")
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed open keyfile directory: %s", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	currentBackend = &keyfileBackend{dir: dir, target: target}
+	forgetVersion()
+	return nil
+}
+
+type keyfileBackend struct {
+	dir    string
+	target Version
+}
+
+type keyfileConnection struct {
+	path    string
+	options map[string]string
+}
+
+func (b *keyfileBackend) readAll() ([]keyfileConnection, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, err
+	}
+	connections := []keyfileConnection{}
+	for _, entry := range entries {
+		name := entry.Name()
+		// NetworkManager skips hidden and backup files, e.g. ones left by editors
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		path := filepath.Join(b.dir, name)
+		f, err := keyfile.Read(path)
+		if err != nil {
+			log.Warnf("Bad keyfile %s: %s", path, err)
+			continue
+		}
+		connections = append(connections, keyfileConnection{path: path, options: keyfileToOptions(f)})
+	}
+	return connections, nil
+}
+
+// Looks up connection by id or UUID like `nmcli connection show` does
+func (b *keyfileBackend) find(name string) (*keyfileConnection, error) {
+	connections, err := b.readAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, conn := range connections {
+		if conn.options["connection.id"] == name || conn.options["connection.uuid"] == name {
+			return &conn, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrConnectionNotFound, name)
+}
+
+func (b *keyfileBackend) listConnections() ([]Connection, error) {
+	connections, err := b.readAll()
+	if err != nil {
+		return nil, err
+	}
+	list := []Connection{}
+	for _, conn := range connections {
+		list = append(list, Connection{
+			Name:   conn.options["connection.id"],
+			UUID:   conn.options["connection.uuid"],
+			Type:   ConnectionType(conn.options["connection.type"]),
+			Device: conn.options[OptionKeyInterfaceName],
+		})
+	}
+	return list, nil
+}
+
+func (b *keyfileBackend) showConnection(name string) (*Connection, error) {
+	conn, err := b.find(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Connection{
+		keyValOutput: &keyValOutput{options: conn.options},
+
+		Name:   conn.options["connection.id"],
+		UUID:   conn.options["connection.uuid"],
+		Type:   ConnectionType(conn.options["connection.type"]),
+		Device: conn.options[OptionKeyInterfaceName],
+	}, nil
+}
+
+func (b *keyfileBackend) addConnection(t ConnectionType, deviceName, connectionName string, params []string) error {
+	if len(params)%2 != 0 {
+		return fmt.Errorf("odd number of connection parameters")
+	}
+	uuid, err := newUUID()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(b.dir, keyfileName(connectionName))
+	if _, err := os.Stat(path); err == nil {
+		path = filepath.Join(b.dir, keyfileName(connectionName+"-"+uuid))
+	}
+
+	options := map[string]string{
+		"connection.type":      connectionTypeAliases.resolve(string(t)),
+		OptionKeyInterfaceName: deviceName,
+		"connection.id":        connectionName,
+		"connection.uuid":      uuid,
+	}
+	for i := 0; i < len(params); i += 2 {
+		options[optionKeyAliases.resolve(params[i])] = params[i+1]
+	}
+	return writeKeyfile(path, options)
+}
+
+func (b *keyfileBackend) modifyConnection(c *Connection, params []string) error {
+	if len(params)%2 != 0 {
+		return fmt.Errorf("odd number of connection parameters")
+	}
+	conn, err := b.find(c.UUID)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(params); i += 2 {
+		if params[i] == removeSettingParam {
+			prefix := optionKeyAliases.resolve(params[i+1]) + "."
+			for option := range conn.options {
+				if strings.HasPrefix(option, prefix) {
+					delete(conn.options, option)
+				}
+			}
+			continue
+		}
+		conn.options[optionKeyAliases.resolve(params[i])] = params[i+1]
+	}
+	return writeKeyfile(conn.path, conn.options)
+}
+
+func (b *keyfileBackend) deleteConnection(c *Connection) error {
+	conn, err := b.find(c.UUID)
+	if err != nil {
+		return err
+	}
+	return os.Remove(conn.path)
+}
+
+func (b *keyfileBackend) activateConnection(c *Connection, timeout time.Duration) error {
+	return ErrNetworkManagerOffline
+}
+
+func (b *keyfileBackend) deactivateConnection(c *Connection) error {
+	return ErrNetworkManagerOffline
+}
+
+func (b *keyfileBackend) listDevices() ([]string, error) {
+	return nil, ErrNetworkManagerOffline
+}
+
+func (b *keyfileBackend) showDevice(name string) (*Device, error) {
+	return nil, ErrNetworkManagerOffline
+}
+
+func (b *keyfileBackend) setDeviceManaged(name string, managed bool) error {
+	return ErrNetworkManagerOffline
+}
+
+func (b *keyfileBackend) reapplyDevice(name string) error {
+	return ErrNetworkManagerOffline
+}
+
+func (b *keyfileBackend) requestWifiScan(deviceName string) error {
+	return ErrNetworkManagerOffline
+}
+
+func (b *keyfileBackend) listWifiNetworks(deviceName string) ([]WifiNetwork, error) {
+	return nil, ErrNetworkManagerOffline
+}
+
+// Profiles are going to be read by NetworkManager installed on the system
+func (b *keyfileBackend) version() (string, error) {
+	return b.target.String(), nil
+}
+
+var keyfileNameUnsafeRegex = regexp.MustCompile(`[/\\]|^\.`)
+
+func keyfileName(connectionName string) string {
+	return keyfileNameUnsafeRegex.ReplaceAllString(connectionName, "_") + keyfileExtension
+}
+
+// Keyfiles use short names for some settings
+var keyfileSettingAliases = nmcliAliases{
+	"wifi":          "802-11-wireless",
+	"wifi-security": "802-11-wireless-security",
+	"ethernet":      "802-3-ethernet",
+}
+
+type SecretFlags = int
+
+// Secret flags tell who stores the secret, only system owned secrets are
+// written to keyfiles
+const (
+	SecretFlagNone        SecretFlags = 0
+	SecretFlagAgentOwned  SecretFlags = 0x1
+	SecretFlagNotSaved    SecretFlags = 0x2
+	SecretFlagNotRequired SecretFlags = 0x4
+)
+
+// Addresses, routes and routing rules are stored as numbered keys, e.g.
+// address1, route2 and route2_options
+var keyfileNumberedKeyRegex = regexp.MustCompile(`^(address(?:es)?|route|routing-rule)(\d+)(_options)?$`)
+
+var keyfileNumberedOptions = map[string]string{
+	"address":      "addresses",
+	"addresses":    "addresses",
+	"route":        "routes",
+	"routing-rule": "routing-rules",
+}
+
+// Converts keyfile to options in nmcli format
+func keyfileToOptions(f *keyfile.File) map[string]string {
+	options := map[string]string{}
+	for _, group := range f.Groups() {
+		setting := keyfileSettingAliases.resolve(group.Name)
+		numbered := map[string]map[int]string{}
+		for _, key := range group.Keys() {
+			option := setting + "." + key
+			value, _ := group.Get(key)
+
+			if match := keyfileNumberedKeyRegex.FindStringSubmatch(key); match != nil {
+				if match[3] != "" {
+					// Attributes are read together with the route
+					continue
+				}
+				n, _ := strconv.Atoi(match[2])
+				name := setting + "." + keyfileNumberedOptions[match[1]]
+				if numbered[name] == nil {
+					numbered[name] = map[int]string{}
+				}
+				switch match[1] {
+				case "route":
+					attributes, _ := group.Get(key + "_options")
+					numbered[name][n] = parseKeyfileRoute(value, attributes)
+				case "routing-rule":
+					numbered[name][n] = value
+				default:
+					// address1=192.168.1.10/24,192.168.1.1 where gateway is optional
+					address, gateway, _ := strings.Cut(value, ",")
+					numbered[name][n] = address
+					if _, ok := group.Get("gateway"); !ok && gateway != "" && n == 1 {
+						options[setting+".gateway"] = gateway
+					}
+				}
+				continue
+			}
+
+			switch dbusOptionKinds[option] {
+			case dbusOptionBool:
+				value = map[string]string{"true": TrueValue, "false": "no"}[value]
+			case dbusOptionTernary:
+				value = map[string]string{"1": TrueValue, "0": "no", "-1": "default"}[value]
+			case dbusOptionStrings, dbusOptionIP4List, dbusOptionIP6List:
+				items, _ := group.GetList(key)
+				value = strings.Join(items, ",")
+			case dbusOptionBytes:
+				value = parseKeyfileBytes(value)
+			}
+			if names, ok := dbusOptionEnums[option]; ok {
+				value = normalizeEnumOption(value, names)
+			}
+			options[option] = value
+		}
+
+		for option, values := range numbered {
+			indexes := []int{}
+			for n := range values {
+				indexes = append(indexes, n)
+			}
+			sort.Ints(indexes)
+			list := []string{}
+			for _, n := range indexes {
+				list = append(list, values[n])
+			}
+			options[option] = strings.Join(list, ",")
+		}
+	}
+	if t, ok := options["connection.type"]; ok {
+		options["connection.type"] = connectionTypeAliases.resolve(t)
+	}
+	return options
+}
+
+// Converts route1=10.0.0.0/8,192.168.1.1,100 and route1_options=table=200
+// to nmcli syntax, next hop and metric are optional
+func parseKeyfileRoute(value, attributes string) string {
+	parts := strings.Split(value, ",")
+	fields := []string{parts[0]}
+	if len(parts) > 1 {
+		if nextHop := net.ParseIP(parts[1]); nextHop != nil && !nextHop.IsUnspecified() {
+			fields = append(fields, parts[1])
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		fields = append(fields, parts[2])
+	}
+	return strings.Join(append(fields, splitOptionList(attributes)...), " ")
+}
+
+// SSIDs which aren't valid text are stored as list of byte values, e.g. 77;105;
+func parseKeyfileBytes(value string) string {
+	if !strings.HasSuffix(value, ";") {
+		return value
+	}
+	data := []byte{}
+	for _, item := range strings.Split(strings.TrimSuffix(value, ";"), ";") {
+		b, err := strconv.ParseUint(item, 10, 8)
+		if err != nil {
+			return value
+		}
+		data = append(data, byte(b))
+	}
+	return string(data)
+}
+
+// Converts options in nmcli format to keyfile. Options of other objects like
+// GENERAL.STATE, empty ones and secrets not owned by system are skipped.
+func optionsToKeyfile(options map[string]string) *keyfile.File {
+	names := []string{}
+	for option := range options {
+		names = append(names, option)
+	}
+	sort.Strings(names)
+	// NetworkManager writes connection setting first
+	sort.SliceStable(names, func(i, j int) bool {
+		return strings.HasPrefix(names[i], "connection.") && !strings.HasPrefix(names[j], "connection.")
+	})
+
+	f := keyfile.New()
+	for _, option := range names {
+		value := options[option]
+		setting, key, ok := strings.Cut(option, ".")
+		if !ok || value == "" || strings.ToUpper(setting) == setting || !isSystemSecret(options, option) {
+			continue
+		}
+		group := f.AddGroup(keyfileGroupName(setting))
+
+		if names, ok := dbusOptionEnums[option]; ok {
+			if i := slices.Index(names, normalizeEnumOption(value, names)); i >= 0 {
+				value = strconv.Itoa(i)
+			}
+		}
+		switch dbusOptionKinds[option] {
+		case dbusOptionAddresses:
+			for i, address := range splitList(value) {
+				group.Set("address"+strconv.Itoa(i+1), address)
+			}
+			continue
+		case dbusOptionRoutes:
+			for i, item := range splitOptionList(value) {
+				route, err := ParseRoute(item)
+				if err != nil {
+					log.Warn("Skipping invalid route", "route", item, "error", err)
+					continue
+				}
+				setKeyfileRoute(group, "route"+strconv.Itoa(i+1), route)
+			}
+			continue
+		case dbusOptionRoutingRules:
+			for i, rule := range splitOptionList(value) {
+				group.Set("routing-rule"+strconv.Itoa(i+1), rule)
+			}
+			continue
+		case dbusOptionStrings, dbusOptionIP4List, dbusOptionIP6List:
+			group.SetList(key, splitList(value))
+			continue
+		case dbusOptionBool:
+			if v, err := optionToValue(dbusOptionBool, value); err == nil {
+				value = strconv.FormatBool(v.(bool))
+			}
+		case dbusOptionTernary:
+			if v, err := optionToValue(dbusOptionTernary, value); err == nil {
+				value = strconv.Itoa(int(v.(int32)))
+			}
+		}
+		if option == "connection.type" {
+			value = keyfileGroupName(value)
+		}
+		if strings.HasSuffix(key, "-flags") {
+			// nmcli shows flags like "1 (agent-owned)"
+			value, _, _ = strings.Cut(value, " ")
+		}
+		group.Set(key, value)
+	}
+	return f
+}
+
+func setKeyfileRoute(group *keyfile.Group, key string, route Route) {
+	fields := []string{route.Destination.String()}
+	if route.NextHop != nil || route.Metric != RouteMetricDefault {
+		nextHop := ""
+		if route.NextHop != nil {
+			nextHop = route.NextHop.String()
+		}
+		fields = append(fields, nextHop)
+	}
+	if route.Metric != RouteMetricDefault {
+		fields = append(fields, strconv.Itoa(route.Metric))
+	}
+	group.Set(key, strings.Join(fields, ","))
+
+	if attributes := route.attributeFields(); len(attributes) > 0 {
+		group.Set(key+"_options", strings.Join(attributes, ","))
+	}
+}
+
+// Returns short name of the setting NetworkManager uses in keyfiles
+func keyfileGroupName(setting string) string {
+	for alias, name := range keyfileSettingAliases {
+		if name == setting {
+			return alias
+		}
+	}
+	return setting
+}
+
+// Reports whether option is not a secret or a secret stored by system
+func isSystemSecret(options map[string]string, option string) bool {
+	flags, ok := options[option+"-flags"]
+	if !ok {
+		return true
+	}
+	value, _, _ := strings.Cut(flags, " ")
+	n, err := strconv.Atoi(value)
+	return err != nil || n&(SecretFlagAgentOwned|SecretFlagNotSaved) == 0
+}
+
+func writeKeyfile(path string, options map[string]string) error {
+	if err := optionsToKeyfile(options).Write(path); err != nil {
+		return fmt.Errorf("failed write keyfile %s: %s", path, err)
+	}
+	return nil
+}
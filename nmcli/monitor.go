@@ -0,0 +1,201 @@
+package nmcli
+
+import (
+	"bufio"
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zarinit-routers/cli"
+)
+
+type EventType string
+
+const (
+	EventTypeDeviceStateChanged    EventType = "device-state-changed"
+	EventTypeDeviceAdded           EventType = "device-added"
+	EventTypeDeviceRemoved         EventType = "device-removed"
+	EventTypeConnectionActivated   EventType = "connection-activated"
+	EventTypeConnectionDeactivated EventType = "connection-deactivated"
+	EventTypeConnectivityChanged   EventType = "connectivity-changed"
+	EventTypeStateChanged          EventType = "state-changed" // Global NetworkManager state
+	EventTypePrimaryConnection     EventType = "primary-connection-changed"
+	EventTypeProfileAdded          EventType = "profile-added"
+	EventTypeProfileRemoved        EventType = "profile-removed"
+	EventTypeProfileChanged        EventType = "profile-changed"
+	EventTypeNetworkManagerStarted EventType = "networkmanager-started"
+	EventTypeNetworkManagerStopped EventType = "networkmanager-stopped"
+	EventTypeUnknown               EventType = "unknown"
+)
+
+type Event struct {
+	Type       EventType `json:"type"`
+	Time       time.Time `json:"time"`
+	Device     string    `json:"device,omitempty"`
+	Connection string    `json:"connection,omitempty"`
+	// New state of device, connectivity or NetworkManager depending on event type
+	State string `json:"state,omitempty"`
+	// Line printed by nmcli
+	Raw string `json:"raw"`
+}
+
+const (
+	DeviceStateConnected    = "connected"
+	DeviceStateDisconnected = "disconnected"
+	DeviceStateDeactivating = "deactivating"
+	DeviceStateFailed       = "connection failed"
+	DeviceStateUnavailable  = "unavailable"
+	DeviceStateUnmanaged    = "unmanaged"
+)
+
+// Delay before restarting monitor process after it died
+const monitorRestartDelay = time.Second
+
+var (
+	monitorNMStateRegex      = regexp.MustCompile(`^Networkmanager is now in the '(.*)' state$`)
+	monitorConnectivityRegex = regexp.MustCompile(`^Connectivity is now '(.*)'$`)
+	monitorPrimaryRegex      = regexp.MustCompile(`^'(.*)' is now the primary connection$`)
+	monitorUsingRegex        = regexp.MustCompile(`^(.*): using connection '(.*)'$`)
+	monitorProfileRegex      = regexp.MustCompile(`^(.*): connection profile (created|removed|changed)$`)
+	monitorDeviceRegex       = regexp.MustCompile(`^(.*): device (created|removed)$`)
+	monitorDeviceStateRegex  = regexp.MustCompile(`^([^:]+): (.*)$`)
+)
+
+// Runs `nmcli monitor` and delivers parsed events until ctx is done. The
+// monitor process is restarted if it dies. Channel is closed when ctx is done.
+func Monitor(ctx context.Context) <-chan Event {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		parser := newMonitorParser()
+		for {
+			if err := runMonitor(ctx, parser, events); err != nil {
+				log.Warn("NetworkManager monitor stopped", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(monitorRestartDelay):
+				log.Debug("Restarting NetworkManager monitor")
+			}
+		}
+	}()
+	return events
+}
+
+func runMonitor(ctx context.Context, parser *monitorParser, events chan<- Event) error {
+	cmd, output, err := cli.Start(ctx, "nmcli", "monitor")
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		for _, event := range parser.parse(scanner.Text()) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				_ = cmd.Wait()
+				return nil
+			}
+		}
+	}
+	return cmd.Wait()
+}
+
+// Remembers which connection is used by each device, so device state changes
+// can be reported as connection activation and deactivation.
+type monitorParser struct {
+	deviceConnections map[string]string
+}
+
+func newMonitorParser() *monitorParser {
+	return &monitorParser{deviceConnections: map[string]string{}}
+}
+
+func (p *monitorParser) parse(line string) []Event {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	event := Event{Type: EventTypeUnknown, Time: time.Now(), Raw: line}
+
+	if m := monitorNMStateRegex.FindStringSubmatch(line); m != nil {
+		event.Type, event.State = EventTypeStateChanged, m[1]
+		return []Event{event}
+	}
+	if m := monitorConnectivityRegex.FindStringSubmatch(line); m != nil {
+		event.Type, event.State = EventTypeConnectivityChanged, m[1]
+		return []Event{event}
+	}
+	if m := monitorPrimaryRegex.FindStringSubmatch(line); m != nil {
+		event.Type, event.Connection = EventTypePrimaryConnection, m[1]
+		return []Event{event}
+	}
+	switch line {
+	case "There's no primary connection":
+		event.Type = EventTypePrimaryConnection
+		return []Event{event}
+	case "Networkmanager is now running":
+		event.Type = EventTypeNetworkManagerStarted
+		return []Event{event}
+	case "Networkmanager is stopped":
+		event.Type = EventTypeNetworkManagerStopped
+		return []Event{event}
+	}
+	if m := monitorProfileRegex.FindStringSubmatch(line); m != nil {
+		event.Connection = m[1]
+		event.Type = map[string]EventType{
+			"created": EventTypeProfileAdded,
+			"removed": EventTypeProfileRemoved,
+			"changed": EventTypeProfileChanged,
+		}[m[2]]
+		return []Event{event}
+	}
+	if m := monitorUsingRegex.FindStringSubmatch(line); m != nil {
+		p.deviceConnections[m[1]] = m[2]
+		return nil
+	}
+	if m := monitorDeviceRegex.FindStringSubmatch(line); m != nil {
+		event.Device = m[1]
+		if m[2] == "created" {
+			event.Type = EventTypeDeviceAdded
+		} else {
+			event.Type = EventTypeDeviceRemoved
+			delete(p.deviceConnections, m[1])
+		}
+		return []Event{event}
+	}
+	if m := monitorDeviceStateRegex.FindStringSubmatch(line); m != nil {
+		return p.deviceStateEvents(event, m[1], m[2])
+	}
+
+	log.Debug("Unknown monitor line", "line", line)
+	return []Event{event}
+}
+
+func (p *monitorParser) deviceStateEvents(event Event, device, state string) []Event {
+	event.Type = EventTypeDeviceStateChanged
+	event.Device = device
+	event.State = state
+	event.Connection = p.deviceConnections[device]
+	events := []Event{event}
+
+	if event.Connection == "" {
+		return events
+	}
+	switch {
+	case state == DeviceStateConnected:
+		event.Type = EventTypeConnectionActivated
+		events = append(events, event)
+	case state == DeviceStateDisconnected,
+		state == DeviceStateFailed,
+		state == DeviceStateUnavailable,
+		state == DeviceStateUnmanaged:
+		event.Type = EventTypeConnectionDeactivated
+		events = append(events, event)
+		delete(p.deviceConnections, device)
+	}
+	return events
+}
@@ -20,13 +20,8 @@ func parseKeyValOutput(output []byte) map[string]string {
 	dict := map[string]string{}
 	lines := strings.Split(string(output), "\n")
 	for _, l := range lines {
-		words := strings.Split(l, ":")
-		if len(words) < 2 {
-			dict[words[0]] = ""
-			continue
-		}
-
-		dict[words[0]] = words[1]
+		key, value, _ := strings.Cut(l, ":")
+		dict[key] = strings.Join(splitTerseLine(value), ":")
 	}
 	return dict
 }
@@ -52,3 +47,26 @@ func (c *keyValOutput) getOption(optionName string) string {
 	log.Debug("Getting option", "option", optionName, "value", c.options[optionName])
 	return c.options[optionName]
 }
+
+// splitTerseLine splits a line of terse nmcli output into its fields.
+// In terse mode nmcli escapes ':' and '\' inside values with a backslash.
+func splitTerseLine(line string) []string {
+	fields := []string{}
+	var field strings.Builder
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+	return append(fields, field.String())
+}
@@ -0,0 +1,356 @@
+// Documentation for routing options:
+//
+// - https://www.networkmanager.dev/docs/api/latest/settings-ipv4.html
+package nmcli
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	OptionKeyIP4Routes       = "ipv4.routes"
+	OptionKeyIP6Routes       = "ipv6.routes"
+	OptionKeyIP4RouteMetric  = "ipv4.route-metric"
+	OptionKeyIP6RouteMetric  = "ipv6.route-metric"
+	OptionKeyIP4RouteTable   = "ipv4.route-table"
+	OptionKeyIP6RouteTable   = "ipv6.route-table"
+	OptionKeyIP4RoutingRules = "ipv4.routing-rules"
+	OptionKeyIP6RoutingRules = "ipv6.routing-rules"
+)
+
+// Metric of a route which uses route-metric of the connection
+const RouteMetricDefault = -1
+
+// Static route in nmcli syntax "dest/prefix [next-hop] [metric] [attr=value...]"
+type Route struct {
+	Destination *net.IPNet `json:"destination"`
+	// Nil for routes to directly connected networks
+	NextHop net.IP `json:"nextHop"`
+	Metric  int    `json:"metric"`
+	// Zero means route-table of the connection
+	Table int `json:"table"`
+	// Other attributes, e.g. onlink=true or src=192.168.1.1
+	Attributes map[string]string `json:"attributes"`
+}
+
+func ParseRoute(value string) (Route, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return Route{}, fmt.Errorf("empty route")
+	}
+	route := Route{Metric: RouteMetricDefault, Attributes: map[string]string{}}
+	destination, err := parsePrefix(fields[0])
+	if err != nil {
+		return Route{}, fmt.Errorf("invalid route destination %q: %s", fields[0], err)
+	}
+	route.Destination = destination
+
+	for _, field := range fields[1:] {
+		name, attr, isAttribute := strings.Cut(field, "=")
+		switch {
+		case isAttribute && name == "table":
+			if route.Table, err = strconv.Atoi(attr); err != nil {
+				return Route{}, fmt.Errorf("invalid route table %q", attr)
+			}
+		case isAttribute:
+			route.Attributes[name] = attr
+		case route.NextHop == nil && route.Metric == RouteMetricDefault && net.ParseIP(field) != nil:
+			route.NextHop = net.ParseIP(field)
+		case route.Metric == RouteMetricDefault:
+			if route.Metric, err = strconv.Atoi(field); err != nil || route.Metric < 0 {
+				return Route{}, fmt.Errorf("invalid route metric %q", field)
+			}
+		default:
+			return Route{}, fmt.Errorf("unexpected %q in route %q", field, value)
+		}
+	}
+	return route, nil
+}
+
+// Parses address with optional prefix length, single host is assumed without it
+func parsePrefix(value string) (*net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("not an IP address")
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	return network, err
+}
+
+func (r Route) String() string {
+	fields := []string{r.Destination.String()}
+	if r.NextHop != nil {
+		fields = append(fields, r.NextHop.String())
+	}
+	if r.Metric != RouteMetricDefault {
+		fields = append(fields, strconv.Itoa(r.Metric))
+	}
+	return strings.Join(append(fields, r.attributeFields()...), " ")
+}
+
+// Returns table and other attributes as name=value pairs
+func (r Route) attributeFields() []string {
+	fields := []string{}
+	if r.Table != 0 {
+		fields = append(fields, "table="+strconv.Itoa(r.Table))
+	}
+	names := []string{}
+	for name := range r.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fields = append(fields, name+"="+r.Attributes[name])
+	}
+	return fields
+}
+
+func (r Route) family() IPFamily {
+	if r.Destination.IP.To4() != nil {
+		return IPFamily4
+	}
+	return IPFamily6
+}
+
+func (c *Connection) GetRoutes(family IPFamily) ([]Route, error) {
+	routes := []Route{}
+	for _, value := range splitOptionList(c.getOption(family + ".routes")) {
+		route, err := ParseRoute(value)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// Replaces static routes of the IP family
+func (c *Connection) SetRoutes(family IPFamily, routes []Route) error {
+	values := []string{}
+	for _, route := range routes {
+		if route.Destination == nil {
+			return fmt.Errorf("route has no destination")
+		}
+		if route.family() != family {
+			return fmt.Errorf("route %s doesn't belong to %s", route, family)
+		}
+		values = append(values, route.String())
+	}
+	return c.setOption(family+".routes", strings.Join(values, ","))
+}
+
+// Adds static route unless the same one exists, family is taken from destination
+func (c *Connection) AddRoute(route Route) error {
+	if route.Destination == nil {
+		return fmt.Errorf("route has no destination")
+	}
+	routes, err := c.GetRoutes(route.family())
+	if err != nil {
+		return err
+	}
+	for _, existing := range routes {
+		if existing.String() == route.String() {
+			return nil
+		}
+	}
+	return c.SetRoutes(route.family(), append(routes, route))
+}
+
+// Removes routes to destination via next hop, nil next hop matches any
+func (c *Connection) RemoveRoute(destination *net.IPNet, nextHop net.IP) error {
+	family := Route{Destination: destination}.family()
+	routes, err := c.GetRoutes(family)
+	if err != nil {
+		return err
+	}
+	kept := []Route{}
+	for _, route := range routes {
+		sameNextHop := nextHop == nil || route.NextHop.Equal(nextHop)
+		if route.Destination.String() == destination.String() && sameNextHop {
+			continue
+		}
+		kept = append(kept, route)
+	}
+	if len(kept) == len(routes) {
+		return fmt.Errorf("no route to %s on connection %q", destination, c.Name)
+	}
+	return c.SetRoutes(family, kept)
+}
+
+// Returns metric of routes of the connection, -1 means NetworkManager default
+func (c *Connection) GetRouteMetric(family IPFamily) int {
+	metric, err := strconv.Atoi(c.getOption(family + ".route-metric"))
+	if err != nil {
+		return RouteMetricDefault
+	}
+	return metric
+}
+
+// Sets metric of routes of the connection, including default route. Lower
+// metric is preferred, -1 restores NetworkManager default.
+func (c *Connection) SetRouteMetric(family IPFamily, metric int) error {
+	return c.setOption(family+".route-metric", strconv.Itoa(metric))
+}
+
+// Returns table routes of the connection are added to, 0 means main table
+func (c *Connection) GetRouteTable(family IPFamily) int {
+	table, _ := strconv.Atoi(c.getOption(family + ".route-table"))
+	return table
+}
+
+func (c *Connection) SetRouteTable(family IPFamily, table int) error {
+	if table < 0 {
+		return fmt.Errorf("invalid route table %d", table)
+	}
+	return c.setOption(family+".route-table", strconv.Itoa(table))
+}
+
+// Policy routing rule in `ip rule` syntax, e.g. "priority 100 from 10.0.0.0/24 table 200"
+type RoutingRule struct {
+	Priority int `json:"priority"`
+	// Rule matches packets which don't match selectors
+	Invert bool   `json:"invert"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	IIF    string `json:"iif"`
+	OIF    string `json:"oif"`
+	// Mark with optional mask, e.g. 0x1/0xff
+	FWMark string `json:"fwmark"`
+	Table  int    `json:"table"`
+	// Selectors and actions not covered by fields, in nmcli syntax
+	Extra []string `json:"extra"`
+}
+
+func ParseRoutingRule(value string) (RoutingRule, error) {
+	rule := RoutingRule{Extra: []string{}}
+	fields := strings.Fields(value)
+	for i := 0; i < len(fields); i++ {
+		keyword := fields[i]
+		if keyword == "not" {
+			rule.Invert = true
+			continue
+		}
+		if i+1 >= len(fields) {
+			return RoutingRule{}, fmt.Errorf("no value for %q in routing rule %q", keyword, value)
+		}
+		arg := fields[i+1]
+		i++
+
+		var err error
+		switch keyword {
+		case "priority":
+			rule.Priority, err = strconv.Atoi(arg)
+		case "table":
+			rule.Table, err = strconv.Atoi(arg)
+		case "from":
+			rule.From = arg
+		case "to":
+			rule.To = arg
+		case "iif":
+			rule.IIF = arg
+		case "oif":
+			rule.OIF = arg
+		case "fwmark":
+			rule.FWMark = arg
+		default:
+			rule.Extra = append(rule.Extra, keyword, arg)
+		}
+		if err != nil {
+			return RoutingRule{}, fmt.Errorf("invalid %s %q in routing rule", keyword, arg)
+		}
+	}
+	return rule, nil
+}
+
+func (r RoutingRule) String() string {
+	fields := []string{"priority", strconv.Itoa(r.Priority)}
+	if r.Invert {
+		fields = append(fields, "not")
+	}
+	for _, selector := range [][2]string{
+		{"from", r.From}, {"to", r.To}, {"iif", r.IIF}, {"oif", r.OIF}, {"fwmark", r.FWMark},
+	} {
+		if selector[1] != "" {
+			fields = append(fields, selector[0], selector[1])
+		}
+	}
+	fields = append(fields, r.Extra...)
+	if r.Table != 0 {
+		fields = append(fields, "table", strconv.Itoa(r.Table))
+	}
+	return strings.Join(fields, " ")
+}
+
+func (c *Connection) GetRoutingRules(family IPFamily) ([]RoutingRule, error) {
+	rules := []RoutingRule{}
+	for _, value := range splitOptionList(c.getOption(family + ".routing-rules")) {
+		rule, err := ParseRoutingRule(value)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (c *Connection) SetRoutingRules(family IPFamily, rules []RoutingRule) error {
+	values := []string{}
+	for _, rule := range rules {
+		values = append(values, rule.String())
+	}
+	return c.setOption(family+".routing-rules", strings.Join(values, ","))
+}
+
+// Adds routing rule unless the same one exists
+func (c *Connection) AddRoutingRule(family IPFamily, rule RoutingRule) error {
+	rules, err := c.GetRoutingRules(family)
+	if err != nil {
+		return err
+	}
+	for _, existing := range rules {
+		if existing.String() == rule.String() {
+			return nil
+		}
+	}
+	return c.SetRoutingRules(family, append(rules, rule))
+}
+
+// Removes rules with the priority
+func (c *Connection) RemoveRoutingRule(family IPFamily, priority int) error {
+	rules, err := c.GetRoutingRules(family)
+	if err != nil {
+		return err
+	}
+	kept := []RoutingRule{}
+	for _, rule := range rules {
+		if rule.Priority != priority {
+			kept = append(kept, rule)
+		}
+	}
+	if len(kept) == len(rules) {
+		return fmt.Errorf("no routing rule with priority %d on connection %q", priority, c.Name)
+	}
+	return c.SetRoutingRules(family, kept)
+}
+
+// Splits comma separated list of values which contain spaces
+func splitOptionList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,94 @@
+package nmcli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/zarinit-routers/cli/iw"
+)
+
+// NetworkManager has no transmit power option, so it is restored by a
+// dispatcher script each time the connection comes up
+var DispatcherDirectory = "/etc/NetworkManager/dispatcher.d"
+
+const txPowerScriptPrefix = "90-txpower-"
+
+// Sets transmit power of the connection's device and keeps it across
+// reactivations. Power in dBm is ignored in auto mode, otherwise it is checked
+// against regulatory limits even if the connection isn't active.
+func (c *WirelessConnection) SetTxPower(mode iw.TxPowerMode, dBm float64) error {
+	switch mode {
+	case iw.TxPowerModeAuto:
+	case iw.TxPowerModeFixed, iw.TxPowerModeLimit:
+		if err := c.validateTxPower(dBm); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown transmit power mode %q", mode)
+	}
+	if c.IsActive() {
+		if err := iw.SetTxPower(c.Device, mode, dBm); err != nil {
+			return fmt.Errorf("failed set transmit power: %s", err)
+		}
+	}
+	if mode == iw.TxPowerModeAuto {
+		return c.ClearTxPower()
+	}
+	mBm := strconv.Itoa(int(dBm * 100))
+	script := strings.Join([]string{
+		"#!/bin/sh",
+		"# Restores transmit power of connection " + strconv.Quote(c.Name),
+		fmt.Sprintf(`[ "$2" = up ] && [ "$CONNECTION_UUID" = %s ] || exit 0`, c.UUID),
+		fmt.Sprintf(`exec iw dev "$1" set txpower %s %s`, mode, mBm),
+		"",
+	}, "\n")
+	// NetworkManager ignores scripts writable by anyone but owner
+	if err := os.WriteFile(c.txPowerScript(), []byte(script), 0o755); err != nil {
+		return fmt.Errorf("failed persist transmit power: %s", err)
+	}
+	return nil
+}
+
+// Active connection is checked against its current channel, inactive one
+// against the channel of the profile or, if NetworkManager picks the channel,
+// against the highest regulatory maximum of the radio
+func (c *WirelessConnection) validateTxPower(dBm float64) error {
+	if c.IsActive() {
+		return iw.ValidateTxPower(c.Device, dBm)
+	}
+	iface, err := iw.GetInterface(c.Device)
+	if err != nil {
+		return fmt.Errorf("can't get interface %q: %s", c.Device, err)
+	}
+	frequency := 0
+	if channel := c.GetChanel(); channel != 0 {
+		frequency = ChannelFrequency(c.GetBand(), channel)
+	}
+	return iw.ValidatePhyTxPower(iface.Phy, frequency, dBm)
+}
+
+// Returns current transmit power of the connection's device in dBm
+func (c *WirelessConnection) GetTxPower() (float64, error) {
+	if !c.IsActive() {
+		return 0, fmt.Errorf("connection %q is not active", c.Name)
+	}
+	return iw.GetTxPower(c.Device)
+}
+
+// Stops restoring transmit power on activation, driver default is used
+// after the next one
+func (c *WirelessConnection) ClearTxPower() error {
+	err := os.Remove(c.txPowerScript())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed remove transmit power script: %s", err)
+	}
+	return nil
+}
+
+func (c *WirelessConnection) txPowerScript() string {
+	return filepath.Join(DispatcherDirectory, txPowerScriptPrefix+c.UUID)
+}
@@ -0,0 +1,193 @@
+package nmcli
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NetworkManager version, e.g. 1.42.4
+type Version struct {
+	Major int `json:"major"`
+	Minor int `json:"minor"`
+	Micro int `json:"micro"`
+}
+
+var versionRegex = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// Finds version in text like "1.42.4-1.fc38" or "nmcli tool, version 1.36.6"
+func ParseVersion(value string) (Version, error) {
+	match := versionRegex.FindStringSubmatch(value)
+	if match == nil {
+		return Version{}, fmt.Errorf("invalid NetworkManager version %q", value)
+	}
+	v := Version{}
+	v.Major, _ = strconv.Atoi(match[1])
+	v.Minor, _ = strconv.Atoi(match[2])
+	v.Micro, _ = strconv.Atoi(match[3])
+	return v, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Micro)
+}
+
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor > other.Minor
+	}
+	return v.Micro >= other.Micro
+}
+
+var detectedVersion struct {
+	sync.Mutex
+	version *Version
+}
+
+// Returns version of NetworkManager the current backend talks to. It is
+// detected once, failed detection is retried on the next call.
+func GetVersion() (Version, error) {
+	detectedVersion.Lock()
+	defer detectedVersion.Unlock()
+	if detectedVersion.version != nil {
+		return *detectedVersion.version, nil
+	}
+	value, err := currentBackend.version()
+	if err != nil {
+		return Version{}, fmt.Errorf("failed detect NetworkManager version: %w", err)
+	}
+	version, err := ParseVersion(value)
+	if err != nil {
+		return Version{}, err
+	}
+	log.Debug("Detected NetworkManager version", "version", version)
+	detectedVersion.version = &version
+	return version, nil
+}
+
+// Backends may talk to different NetworkManager installations
+func forgetVersion() {
+	detectedVersion.Lock()
+	defer detectedVersion.Unlock()
+	detectedVersion.version = nil
+}
+
+// Feature which appeared in some NetworkManager version
+type Capability = string
+
+const (
+	CapabilityRouteTable          Capability = "route-table"
+	CapabilityWPA3Personal        Capability = "wpa3-personal"
+	CapabilityRoutingRules        Capability = "routing-rules"
+	CapabilityAPIsolation         Capability = "ap-isolation"
+	CapabilityWPA3Enterprise      Capability = "wpa3-enterprise"
+	CapabilitySharedDHCPRange     Capability = "shared-dhcp-range"
+	CapabilitySharedDHCPLeaseTime Capability = "shared-dhcp-lease-time"
+)
+
+// Version each capability appeared in
+var capabilityVersions = map[Capability]Version{
+	CapabilityRouteTable:          {1, 10, 0},
+	CapabilityWPA3Personal:        {1, 16, 0},
+	CapabilityRoutingRules:        {1, 18, 0},
+	CapabilityAPIsolation:         {1, 28, 0},
+	CapabilityWPA3Enterprise:      {1, 30, 0},
+	CapabilitySharedDHCPRange:     {1, 42, 0},
+	CapabilitySharedDHCPLeaseTime: {1, 42, 0},
+}
+
+// Options requiring capability, empty value matches any value of the option
+var optionCapabilities = []struct {
+	option     string
+	value      string
+	capability Capability
+}{
+	{OptionKeyIP4RouteTable, "", CapabilityRouteTable},
+	{OptionKeyIP6RouteTable, "", CapabilityRouteTable},
+	{OptionKeyIP4RoutingRules, "", CapabilityRoutingRules},
+	{OptionKeyIP6RoutingRules, "", CapabilityRoutingRules},
+	{OptionKeyWirelessAPIsolation, "", CapabilityAPIsolation},
+	{OptionKeySharedDHCPRange, "", CapabilitySharedDHCPRange},
+	{OptionKeySharedDHCPLease, "", CapabilitySharedDHCPLeaseTime},
+	{OptionKeyWirelessSecurityKeyManagement, KeyManagementWPA3Personal, CapabilityWPA3Personal},
+	{OptionKeyWirelessSecurityKeyManagement, KeyManagementWPA3Enterprise, CapabilityWPA3Enterprise},
+}
+
+var ErrUnsupported = errors.New("unsupported by NetworkManager")
+
+// Capability is missing in NetworkManager version, matches [ErrUnsupported]
+type UnsupportedError struct {
+	Capability Capability `json:"capability"`
+	Version    Version    `json:"version"`
+	Required   Version    `json:"required"`
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%s is unsupported by NetworkManager %d.%d, %d.%d is required",
+		e.Capability, e.Version.Major, e.Version.Minor, e.Required.Major, e.Required.Minor)
+}
+
+func (e *UnsupportedError) Unwrap() error {
+	return ErrUnsupported
+}
+
+// Returns capabilities supported by NetworkManager, sorted by name
+func GetCapabilities() ([]Capability, error) {
+	version, err := GetVersion()
+	if err != nil {
+		return nil, err
+	}
+	capabilities := []Capability{}
+	for capability, required := range capabilityVersions {
+		if version.AtLeast(required) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	sort.Strings(capabilities)
+	return capabilities, nil
+}
+
+// Returns [UnsupportedError] if NetworkManager lacks capability. Capabilities
+// are assumed to be present when version can't be detected, NetworkManager
+// reports the error itself then.
+func RequireCapability(capability Capability) error {
+	required, ok := capabilityVersions[capability]
+	if !ok {
+		return fmt.Errorf("unknown capability %q", capability)
+	}
+	version, err := GetVersion()
+	if err != nil {
+		log.Debug("Skipping capability check", "capability", capability, "error", err)
+		return nil
+	}
+	if !version.AtLeast(required) {
+		return &UnsupportedError{Capability: capability, Version: version, Required: required}
+	}
+	return nil
+}
+
+// Checks options of nmcli `connection add` or `connection modify` params
+func requireParamCapabilities(params []string) error {
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i] == removeSettingParam {
+			continue
+		}
+		option := optionKeyAliases.resolve(strings.TrimLeft(params[i], "+-"))
+		for _, oc := range optionCapabilities {
+			if oc.option != option || (oc.value != "" && oc.value != params[i+1]) {
+				continue
+			}
+			if err := RequireCapability(oc.capability); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
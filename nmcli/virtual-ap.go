@@ -0,0 +1,175 @@
+package nmcli
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zarinit-routers/cli/iw"
+)
+
+// How long to wait for NetworkManager to pick up new interface
+const virtualInterfaceTimeout = 5 * time.Second
+
+// Virtual interfaces are gone after reboot, so udev rules recreate them
+// when their radio appears
+var UdevRulesDirectory = "/etc/udev/rules.d"
+
+const virtualInterfaceRulePrefix = "70-virtual-ap-"
+
+// Radios are matched by permanent address, as phy names may change on reboot
+var ieee80211Directory = "/sys/class/ieee80211"
+
+// Creates virtual interface on the radio of parent device and access point
+// bound to it, so several SSIDs can be served by one radio. Access points of
+// one radio usually have to share the channel.
+func CreateVirtualAccessPoint(parentDevice, iface, connectionName string, security AccessPointSecurity) (*WirelessConnection, error) {
+	parent, err := iw.GetInterface(parentDevice)
+	if err != nil {
+		return nil, fmt.Errorf("can't get interface %q: %s", parentDevice, err)
+	}
+	if _, err := iw.CanAddInterface(parent.Phy, iw.InterfaceTypeAP); err != nil {
+		return nil, err
+	}
+	interfaces, err := iw.GetInterfaces(parent.Phy)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := freeVirtualInterfaceMAC(parent.MAC, interfaces)
+	if err != nil {
+		return nil, err
+	}
+
+	// NetworkManager switches interface to AP mode on activation
+	if err := iw.AddInterface(parent.Phy, iface, iw.InterfaceTypeManaged, mac); err != nil {
+		return nil, fmt.Errorf("failed add virtual interface %q: %s", iface, err)
+	}
+	if err := waitDevice(iface); err != nil {
+		cleanupVirtualInterface(iface)
+		return nil, err
+	}
+	if err := currentBackend.setDeviceManaged(iface, true); err != nil {
+		cleanupVirtualInterface(iface)
+		return nil, fmt.Errorf("failed make %q managed: %w", iface, err)
+	}
+
+	conn, err := CreateWirelessConnectionWithSecurity(iface, connectionName, security)
+	if err != nil {
+		cleanupVirtualInterface(iface)
+		return nil, err
+	}
+	if err := writeVirtualInterfaceRule(parent.Phy, iface, mac, connectionName); err != nil {
+		if deleteErr := DeleteVirtualAccessPoint(conn); deleteErr != nil {
+			log.Warn("Failed clean up virtual access point", "interface", iface, "error", deleteErr)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Deletes access point profile, its virtual interface and udev rule
+func DeleteVirtualAccessPoint(c *WirelessConnection) error {
+	if err := c.Delete(); err != nil {
+		return fmt.Errorf("failed delete connection %q: %w", c.Name, err)
+	}
+	if err := removeVirtualInterfaceRule(c.Device); err != nil {
+		return err
+	}
+	if err := iw.DeleteInterface(c.Device); err != nil {
+		return fmt.Errorf("failed delete interface %q: %s", c.Device, err)
+	}
+	return nil
+}
+
+// Writes udev rule adding the interface with the same address each time the
+// radio appears, NetworkManager then activates the access point profile
+func writeVirtualInterfaceRule(phy, iface, mac, connectionName string) error {
+	radio, err := os.ReadFile(filepath.Join(ieee80211Directory, phy, "macaddress"))
+	if err != nil {
+		return fmt.Errorf("failed get address of radio %q: %s", phy, err)
+	}
+	// udev runs programs with minimal PATH, so absolute path is used
+	executable, err := exec.LookPath("iw")
+	if err != nil {
+		return fmt.Errorf("failed find iw: %s", err)
+	}
+	rule := strings.Join([]string{
+		"# Recreates virtual interface of access point " + strconv.Quote(connectionName),
+		fmt.Sprintf(`ACTION=="add", SUBSYSTEM=="ieee80211", ATTR{macaddress}=="%s", RUN+="%s phy %%k interface add %s type managed addr %s"`,
+			strings.TrimSpace(string(radio)), executable, iface, mac),
+		"",
+	}, "\n")
+	if err := os.WriteFile(virtualInterfaceRule(iface), []byte(rule), 0o644); err != nil {
+		return fmt.Errorf("failed persist virtual interface %q: %s", iface, err)
+	}
+	return nil
+}
+
+func removeVirtualInterfaceRule(iface string) error {
+	err := os.Remove(virtualInterfaceRule(iface))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed remove udev rule of interface %q: %s", iface, err)
+	}
+	return nil
+}
+
+func virtualInterfaceRule(iface string) string {
+	return filepath.Join(UdevRulesDirectory, virtualInterfaceRulePrefix+iface+".rules")
+}
+
+func cleanupVirtualInterface(iface string) {
+	if err := iw.DeleteInterface(iface); err != nil {
+		log.Warn("Failed remove virtual interface", "interface", iface, "error", err)
+	}
+}
+
+func waitDevice(name string) error {
+	deadline := time.Now().Add(virtualInterfaceTimeout)
+	for {
+		_, err := GetDevice(name)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("device %q did not appear in NetworkManager: %w", name, err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// Returns address derived with the lowest index no interface of the radio
+// uses yet, so addresses stay unique after interfaces are deleted
+func freeVirtualInterfaceMAC(parent string, interfaces []iw.Interface) (string, error) {
+	used := []string{}
+	for _, iface := range interfaces {
+		used = append(used, strings.ToLower(iface.MAC))
+	}
+	for index := 1; index < 256; index++ {
+		mac, err := virtualInterfaceMAC(parent, index)
+		if err != nil {
+			return "", err
+		}
+		if !slices.Contains(used, mac) {
+			return mac, nil
+		}
+	}
+	return "", fmt.Errorf("no free address for virtual interface of %s", parent)
+}
+
+// Each BSS needs its own address, derive locally administered one from parent
+func virtualInterfaceMAC(parent string, index int) (string, error) {
+	mac, err := net.ParseMAC(parent)
+	if err != nil {
+		return "", fmt.Errorf("invalid parent address %q: %s", parent, err)
+	}
+	mac[0] |= 0x02
+	mac[len(mac)-1] ^= byte(index)
+	return mac.String(), nil
+}
@@ -0,0 +1,123 @@
+package nmcli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWifiWrongPassword     = errors.New("wrong wifi password")
+	ErrWifiNetworkNotFound   = errors.New("wifi network not found")
+	ErrWifiActivationTimeout = errors.New("wifi activation timed out")
+	ErrWifiActivationFailed  = errors.New("wifi activation failed")
+)
+
+type WifiClientSecurity string
+
+const (
+	WifiClientSecurityOpen WifiClientSecurity = "open"
+	WifiClientSecurityWPA2 WifiClientSecurity = "wpa2" // Also joins WPA2/WPA3 transition networks
+	WifiClientSecurityWPA3 WifiClientSecurity = "wpa3"
+)
+
+type WifiCredentials struct {
+	Security WifiClientSecurity
+	Password string
+	// Hidden networks are probed directly instead of being looked up in scan results
+	Hidden bool
+	// Name of the created profile, defaults to SSID
+	ConnectionName string
+	// Defaults to [DefaultWifiActivationTimeout]
+	Timeout time.Duration
+}
+
+const DefaultWifiActivationTimeout = 60 * time.Second
+
+// Joins existing wireless network with device in station mode, so that the
+// network can be used as an uplink. The profile is kept on success and removed
+// if activation fails.
+func ConnectToWifi(device string, ssid string, credentials WifiCredentials) (*WirelessConnection, error) {
+	if err := ValidateSSID(ssid); err != nil {
+		return nil, err
+	}
+	securityParams, err := wifiClientSecurityParams(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if credentials.ConnectionName == "" {
+		credentials.ConnectionName = ssid
+	}
+	if credentials.Timeout == 0 {
+		credentials.Timeout = DefaultWifiActivationTimeout
+	}
+
+	hidden := WirelessNotHiddenValue
+	if credentials.Hidden {
+		hidden = WirelessHiddenValue
+	}
+	params := []string{
+		"autoconnect", TrueValue,
+		"ssid", ssid,
+		OptionKeyWirelessMode, string(WirelessModeInfrastructure),
+		OptionKeyWirelessHidden, hidden,
+	}
+	params = append(params, securityParams...)
+
+	conn, err := createConnection(ConnectionTypeWIFI, device, credentials.ConnectionName, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed create wifi client connection: %w", err)
+	}
+
+	if err := currentBackend.activateConnection(conn, credentials.Timeout); err != nil {
+		if deleteErr := conn.Delete(); deleteErr != nil {
+			log.Warn("Failed remove wifi client connection after failed activation", "connection", conn.Name, "error", deleteErr)
+		}
+		return nil, fmt.Errorf("failed connect to %q: %w: %w", ssid, wifiActivationError(err), err)
+	}
+
+	return conn.AsWireless()
+}
+
+func wifiClientSecurityParams(credentials WifiCredentials) ([]string, error) {
+	switch credentials.Security {
+	case WifiClientSecurityOpen:
+		return []string{}, nil
+	case WifiClientSecurityWPA2, "":
+		if err := ValidatePassphrase(credentials.Password); err != nil {
+			return nil, err
+		}
+		return []string{
+			OptionKeyWirelessSecurityKeyManagement, KeyManagementWPA2_3Personal,
+			OptionKeyWirelessSecurityPassword, credentials.Password,
+		}, nil
+	case WifiClientSecurityWPA3:
+		if err := ValidatePassphrase(credentials.Password); err != nil {
+			return nil, err
+		}
+		return []string{
+			OptionKeyWirelessSecurityKeyManagement, KeyManagementWPA3Personal,
+			OptionKeyWirelessSecurityPassword, credentials.Password,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown wifi security %q", credentials.Security)
+}
+
+// Maps activation failure to one of wifi errors
+func wifiActivationError(err error) error {
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "secrets were required"),
+		strings.Contains(message, "no secrets"),
+		strings.Contains(message, "802.1x supplicant"):
+		return ErrWifiWrongPassword
+	case strings.Contains(message, "ssid not found"),
+		errors.Is(err, ErrAccessPointNotFound),
+		errors.Is(err, ErrConnectionNotFound):
+		return ErrWifiNetworkNotFound
+	case errors.Is(err, ErrTimeout):
+		return ErrWifiActivationTimeout
+	}
+	return ErrWifiActivationFailed
+}
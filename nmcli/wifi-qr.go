@@ -0,0 +1,116 @@
+package nmcli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Authentication types of WIFI: URI
+const (
+	wifiURITypeWPA    = "WPA"
+	wifiURITypeSAE    = "SAE"
+	wifiURITypeNoPass = "nopass"
+)
+
+var ErrEnterpriseSharingUnsupported = errors.New("enterprise networks can't be shared with a QR code")
+
+// Default side of PNG QR code in pixels
+const DefaultQRCodeSize = 256
+
+// Builds WIFI: URI used by phone cameras to join the network, e.g.
+// WIFI:T:WPA;S:MyNetwork;P:secret;H:true;;
+func (c *WirelessConnection) WifiURI() (string, error) {
+	var t string
+	switch c.GetSecurityProfile() {
+	case SecurityProfileOpen:
+		t = wifiURITypeNoPass
+	case SecurityProfileWPA3Personal:
+		t = wifiURITypeSAE
+	case SecurityProfileWPA2Enterprise, SecurityProfileWPA3Enterprise:
+		return "", ErrEnterpriseSharingUnsupported
+	default:
+		// Transitional networks accept WPA2 clients too
+		t = wifiURITypeWPA
+	}
+
+	fields := []string{"T:" + t, "S:" + escapeWifiURIValue(c.GetSSID())}
+	if t != wifiURITypeNoPass {
+		password := c.GetPassword()
+		if password == "" {
+			return "", fmt.Errorf("password of connection %q is not available", c.Name)
+		}
+		fields = append(fields, "P:"+escapeWifiURIValue(password))
+	}
+	if c.IsHidden() {
+		fields = append(fields, "H:true")
+	}
+	return "WIFI:" + strings.Join(fields, ";") + ";;", nil
+}
+
+// Backslash escapes characters having special meaning in WIFI: URI
+func escapeWifiURIValue(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch r {
+		case '\\', ';', ',', ':', '"':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Renders WIFI: URI of the connection as PNG image, size is side in pixels
+func (c *WirelessConnection) WifiQRCodePNG(size int) ([]byte, error) {
+	qr, err := c.wifiQRCode()
+	if err != nil {
+		return nil, err
+	}
+	return qr.PNG(size)
+}
+
+// Renders WIFI: URI of the connection for terminal output, each module is
+// two spaces with ANSI background color
+func (c *WirelessConnection) WifiQRCodeANSI() (string, error) {
+	qr, err := c.wifiQRCode()
+	if err != nil {
+		return "", err
+	}
+	return renderANSIQRCode(qr.Bitmap()), nil
+}
+
+func (c *WirelessConnection) wifiQRCode() (*qrcode.QRCode, error) {
+	uri, err := c.WifiURI()
+	if err != nil {
+		return nil, err
+	}
+	qr, err := qrcode.New(uri, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("failed encode QR code: %s", err)
+	}
+	return qr, nil
+}
+
+const (
+	ansiBlack = "\x1b[40m  "
+	ansiWhite = "\x1b[47m  "
+	ansiReset = "\x1b[0m"
+)
+
+func renderANSIQRCode(bitmap [][]bool) string {
+	var b strings.Builder
+	for _, row := range bitmap {
+		for _, dark := range row {
+			if dark {
+				b.WriteString(ansiBlack)
+			} else {
+				b.WriteString(ansiWhite)
+			}
+		}
+		b.WriteString(ansiReset + "\n")
+	}
+	return b.String()
+}
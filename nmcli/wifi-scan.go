@@ -0,0 +1,207 @@
+package nmcli
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	WifiListFieldInUse    = "IN-USE"
+	WifiListFieldSSID     = "SSID"
+	WifiListFieldBSSID    = "BSSID"
+	WifiListFieldMode     = "MODE"
+	WifiListFieldChannel  = "CHAN"
+	WifiListFieldFreq     = "FREQ"
+	WifiListFieldRate     = "RATE"
+	WifiListFieldSignal   = "SIGNAL"
+	WifiListFieldSecurity = "SECURITY"
+	WifiListFieldWPAFlags = "WPA-FLAGS"
+	WifiListFieldRSNFlags = "RSN-FLAGS"
+)
+
+var wifiListFields = []string{
+	WifiListFieldInUse,
+	WifiListFieldSSID,
+	WifiListFieldBSSID,
+	WifiListFieldMode,
+	WifiListFieldChannel,
+	WifiListFieldFreq,
+	WifiListFieldRate,
+	WifiListFieldSignal,
+	WifiListFieldSecurity,
+	WifiListFieldWPAFlags,
+	WifiListFieldRSNFlags,
+}
+
+// nmcli prints this value for empty security flags
+const wifiFlagsNone = "(none)"
+
+// Network seen by a wireless device during a scan
+type WifiNetwork struct {
+	InUse     bool     `json:"inUse"`
+	SSID      string   `json:"ssid"`
+	BSSID     string   `json:"bssid"`
+	Mode      string   `json:"mode"`
+	Channel   int      `json:"channel"`
+	Frequency int      `json:"frequency"` // MHz
+	Rate      int      `json:"rate"`      // Mbit/s
+	Signal    uint     `json:"signal"`    // Percent
+	Security  []string `json:"security"`
+	WPAFlags  []string `json:"wpaFlags"`
+	RSNFlags  []string `json:"rsnFlags"`
+}
+
+func (n *WifiNetwork) IsOpen() bool {
+	return len(n.Security) == 0
+}
+
+// 6 GHz band starts at this frequency in MHz, its channel numbers overlap
+// with 5 GHz ones
+const wifiBand6GHzStart = 5925
+
+func (n *WifiNetwork) Band() WirelessBand {
+	switch {
+	case n.Frequency >= wifiBand6GHzStart:
+		return WirelessBand6GHz
+	case n.Frequency >= 5000:
+		return WirelessBand5GHz
+	}
+	return WirelessBand2GHz
+}
+
+type WifiScanOptions struct {
+	// Results younger than MaxAge are returned from cache without calling nmcli
+	MaxAge time.Duration
+	// Rescan is not requested if the previous one happened less than MinRescanInterval ago
+	MinRescanInterval time.Duration
+}
+
+var DefaultWifiScanOptions = WifiScanOptions{
+	MaxAge:            10 * time.Second,
+	MinRescanInterval: 30 * time.Second,
+}
+
+type wifiScanCacheEntry struct {
+	networks    []WifiNetwork
+	listedAt    time.Time
+	rescannedAt time.Time
+}
+
+var (
+	wifiScanCache   = map[string]*wifiScanCacheEntry{}
+	wifiScanCacheMu sync.Mutex
+)
+
+// Scan networks around specified device using [DefaultWifiScanOptions]
+func ScanWifi(device string) ([]WifiNetwork, error) {
+	return ScanWifiWithOptions(device, DefaultWifiScanOptions)
+}
+
+func ScanWifiWithOptions(device string, opts WifiScanOptions) ([]WifiNetwork, error) {
+	wifiScanCacheMu.Lock()
+	defer wifiScanCacheMu.Unlock()
+
+	entry, ok := wifiScanCache[device]
+	if !ok {
+		entry = &wifiScanCacheEntry{}
+		wifiScanCache[device] = entry
+	}
+
+	now := time.Now()
+	if entry.networks != nil && now.Sub(entry.listedAt) < opts.MaxAge {
+		log.Debug("Using cached wifi scan results", "device", device, "age", now.Sub(entry.listedAt))
+		return cloneWifiNetworks(entry.networks), nil
+	}
+
+	if now.Sub(entry.rescannedAt) >= opts.MinRescanInterval {
+		if err := currentBackend.requestWifiScan(device); err != nil {
+			// NetworkManager refuses to rescan too often, stale results are still useful
+			log.Warn("Failed request wifi rescan", "device", device, "error", err)
+		} else {
+			entry.rescannedAt = now
+		}
+	}
+
+	networks, err := currentBackend.listWifiNetworks(device)
+	if err != nil {
+		return nil, fmt.Errorf("failed list wifi networks on device %q: %w", device, err)
+	}
+
+	entry.networks = networks
+	entry.listedAt = now
+	return cloneWifiNetworks(entry.networks), nil
+}
+
+// Cached results are copied so callers can't change them
+func cloneWifiNetworks(networks []WifiNetwork) []WifiNetwork {
+	clone := slices.Clone(networks)
+	for i := range clone {
+		clone[i].Security = slices.Clone(clone[i].Security)
+		clone[i].WPAFlags = slices.Clone(clone[i].WPAFlags)
+		clone[i].RSNFlags = slices.Clone(clone[i].RSNFlags)
+	}
+	return clone
+}
+
+func parseWifiList(output []byte) []WifiNetwork {
+	networks := []WifiNetwork{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		network, err := parseWifiNetwork(line)
+		if err != nil {
+			log.Warnf("Bad wifi network: %s", err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func parseWifiNetwork(line string) (WifiNetwork, error) {
+	fields := splitTerseLine(line)
+	if len(fields) < len(wifiListFields) {
+		log.Debugf("Bad wifi network '%s'", line)
+		return WifiNetwork{}, ErrTooLittleCols
+	}
+
+	return WifiNetwork{
+		InUse:     strings.TrimSpace(fields[0]) == "*",
+		SSID:      fields[1],
+		BSSID:     fields[2],
+		Mode:      fields[3],
+		Channel:   parseLeadingInt(fields[4]),
+		Frequency: parseLeadingInt(fields[5]),
+		Rate:      parseLeadingInt(fields[6]),
+		Signal:    uint(parseLeadingInt(fields[7])),
+		Security:  parseWifiFlags(fields[8]),
+		WPAFlags:  parseWifiFlags(fields[9]),
+		RSNFlags:  parseWifiFlags(fields[10]),
+	}, nil
+}
+
+// Parses values like "2412 MHz" or "54 Mbit/s", returns 0 on failure
+func parseLeadingInt(value string) int {
+	words := strings.Fields(value)
+	if len(words) == 0 {
+		return 0
+	}
+	number, err := strconv.Atoi(words[0])
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+func parseWifiFlags(value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" || value == wifiFlagsNone || value == "--" {
+		return []string{}
+	}
+	return strings.Fields(value)
+}
@@ -9,8 +9,6 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
-
-	"github.com/zarinit-routers/cli"
 )
 
 const (
@@ -33,42 +31,53 @@ func (c *Connection) AsWireless() (*WirelessConnection, error) {
 	}
 	return &WirelessConnection{c}, nil
 }
+
+// Creates access point with WPA2/WPA3 personal security, connection name is used as SSID
 func CreateWirelessConnection(deviceName string, connectionName string, password string) (*WirelessConnection, error) {
-	if len(password) < 8 {
-		return nil, fmt.Errorf("invalid password: must be at least 8 characters long")
+	return CreateWirelessConnectionWithSecurity(deviceName, connectionName, AccessPointSecurity{
+		Profile:  SecurityProfileWPA2WPA3Personal,
+		Password: password,
+	})
+}
+
+// Creates and activates access point, connection name is used as SSID
+func CreateWirelessConnectionWithSecurity(deviceName string, connectionName string, security AccessPointSecurity) (*WirelessConnection, error) {
+	securityParams, err := security.params()
+	if err != nil {
+		return nil, err
+	}
+	return createAccessPoint(deviceName, connectionName, connectionName, securityParams)
+}
+
+func createAccessPoint(deviceName, connectionName, ssid string, additionalCliParams []string) (*WirelessConnection, error) {
+	if err := ValidateSSID(ssid); err != nil {
+		return nil, err
 	}
 
 	dev, err := GetDevice(deviceName)
 	if err != nil {
-		return nil, fmt.Errorf("can't get device %q: %s", deviceName, err)
+		return nil, fmt.Errorf("can't get device %q: %w", deviceName, err)
 	}
 	if !dev.CanBeAccessPoint() {
 		return nil, fmt.Errorf("device %q can't be access point", deviceName)
 	}
 
+	params := []string{
+		"autoconnect", TrueValue,
+		"ssid", ssid,
+		OptionKeyWirelessMode, string(WirelessModeAccessPoint),
+		OptionKeyIP4Method, ConnectionIP4MethodShared,
+	}
 	conn, err := createConnection(
 		ConnectionTypeWIFI, deviceName, connectionName,
-		[]string{
-			"autoconnect", TrueValue,
-			"ssid", connectionName,
-			OptionKeyWirelessSecurityPassword, password,
-			OptionKeyWirelessSecurityKeyManagement, KeyManagementWPA2_3Personal,
-			OptionKeyWirelessSecurityProto, ProtoAllowWPA2RSN,
-			OptionKeyWirelessSecurityGroup, EncryptionAlgCcmp,
-			OptionKeyWirelessSecurityPairwise, EncryptionAlgCcmp,
-		},
+		append(params, additionalCliParams...),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed create base connection: %s", err)
+		return nil, fmt.Errorf("failed create base connection: %w", err)
 	}
 	wireless := WirelessConnection{conn}
-	if _, err := cli.Execute("nmcli", "device", "wifi", "hotspot",
-		"ifname", deviceName,
-		"conn-name", conn.Name,
-		"ssid", wireless.GetSSID(),
-		"password", wireless.GetPassword(),
-	); err != nil {
-		return nil, fmt.Errorf("can't create hotspot: %s", err)
+	if err := wireless.Up(); err != nil {
+		return nil, fmt.Errorf("can't start access point: %w", err)
 	}
 
 	return &wireless, nil
@@ -107,6 +116,9 @@ const (
 	WirelessModeAdhoc          WirelessMode = "adhoc"
 )
 
+func (c *WirelessConnection) GetMode() WirelessMode {
+	return WirelessMode(c.getOption(OptionKeyWirelessMode))
+}
 func (c *WirelessConnection) SetMode(mode WirelessMode) error {
 	return c.setOption(OptionKeyWirelessMode, string(mode))
 }
@@ -116,6 +128,8 @@ type WirelessBand = string
 const (
 	WirelessBand2GHz WirelessBand = "bg"
 	WirelessBand5GHz WirelessBand = "a"
+	// Only reported for scanned networks, profiles can't be restricted to it
+	WirelessBand6GHz WirelessBand = "6GHz"
 )
 
 func (c *WirelessConnection) SetBand(band WirelessBand) error {
@@ -129,6 +143,9 @@ func (c *WirelessConnection) GetSSID() string {
 	return c.getOption(OptionKeyWirelessSSID)
 }
 func (c *WirelessConnection) SetSSID(ssid string) error {
+	if err := ValidateSSID(ssid); err != nil {
+		return err
+	}
 	err := c.setOption(OptionKeyWirelessSSID, ssid)
 	if err == nil {
 		return c.ensureOptionsParsed()
@@ -150,6 +167,9 @@ func (c *WirelessConnection) GetPassword() string {
 	return c.getOption(OptionKeyWirelessSecurityPassword)
 }
 func (c *WirelessConnection) SetPassword(password string) error {
+	if err := ValidatePassphrase(password); err != nil {
+		return err
+	}
 	return c.setOption(OptionKeyWirelessSecurityPassword, password)
 }
 
@@ -193,43 +213,38 @@ const (
 func (c *WirelessConnection) GetSignalStrength() uint {
 	bssid := c.GetBSSID()
 
-	val, err := c.getDeviceData(DeviceDataKeySignalStrength)
-
+	network, err := c.getNetwork()
 	if err != nil {
 		log.Errorf("Failed get wifi signal strength for BSSID '%s': %s", bssid, err)
 		return 0
 	}
-
-	strength, err := strconv.Atoi(string(val))
-	if err != nil {
-		log.Errorf("Failed parse wifi signal strength from '%s': %s", string(val), err)
-	}
-	return uint(strength)
+	return network.Signal
 }
 
-func (c *WirelessConnection) getDeviceData(key DeviceDataKey) (string, error) {
+func (c *WirelessConnection) getNetwork() (*WifiNetwork, error) {
 	bssid := c.GetBSSID()
 
-	val, err := cli.Execute(
-		"nmcli", terseFlag, getFieldsFlag(string(key)),
-		"device", "wifi", "list",
-		"bssid", bssid,
-	)
+	networks, err := currentBackend.listWifiNetworks(c.Device)
 	if err != nil {
 		log.Error("Failed get device data for for connection", "connectionName", c.Name, "connectionBSSID", bssid, "err", err)
-		return "", err
+		return nil, err
+	}
+	for _, network := range networks {
+		if strings.EqualFold(network.BSSID, bssid) {
+			return &network, nil
+		}
 	}
-	return string(val), nil
+	return nil, fmt.Errorf("no network with BSSID %q", bssid)
 }
 
 func (c *WirelessConnection) GetNetworkRate() string {
 	bssid := c.GetBSSID()
 
-	val, err := c.getDeviceData(DeviceDataKeyRate)
-
+	network, err := c.getNetwork()
 	if err != nil {
-		log.Errorf("Failed get wifi signal strength for BSSID '%s': %s", bssid, err)
+		log.Errorf("Failed get wifi network rate for BSSID '%s': %s", bssid, err)
+		return ""
 	}
 
-	return string(val)
+	return fmt.Sprintf("%d Mbit/s", network.Rate)
 }
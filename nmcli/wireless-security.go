@@ -0,0 +1,211 @@
+package nmcli
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	OptionKeyWirelessSecurityPMF = "802-11-wireless-security.pmf"
+	// Setting which holds all security options of wireless connection
+	SettingWirelessSecurity = "802-11-wireless-security"
+)
+
+// Protected management frames
+type WirelessPMF = string
+
+const (
+	WirelessPMFDefault  WirelessPMF = "default"
+	WirelessPMFDisable  WirelessPMF = "disable"
+	WirelessPMFOptional WirelessPMF = "optional"
+	WirelessPMFRequired WirelessPMF = "required"
+)
+
+type SecurityProfile string
+
+const (
+	SecurityProfileOpen             SecurityProfile = "open"
+	SecurityProfileWPAWPA2Personal  SecurityProfile = "wpa-wpa2-personal" // Legacy TKIP clients
+	SecurityProfileWPA2Personal     SecurityProfile = "wpa2-personal"
+	SecurityProfileWPA2WPA3Personal SecurityProfile = "wpa2-wpa3-personal" // Transition mode
+	SecurityProfileWPA3Personal     SecurityProfile = "wpa3-personal"      // SAE only
+	// Detected on client connections only, access points can't authenticate
+	// stations against RADIUS server
+	SecurityProfileWPA2Enterprise SecurityProfile = "wpa2-enterprise"
+	SecurityProfileWPA3Enterprise SecurityProfile = "wpa3-enterprise"
+)
+
+// NetworkManager runs access points with wpa_supplicant, which has no 802.1X
+// authenticator
+var ErrEnterpriseAccessPointUnsupported = fmt.Errorf("enterprise access point: %w", ErrUnsupported)
+
+type AccessPointSecurity struct {
+	Profile SecurityProfile
+	// Passphrase for personal profiles
+	Password string
+	// Defaults to the value suitable for profile
+	PMF WirelessPMF
+}
+
+// Key management, proto, pairwise and group ciphers and default PMF for each
+// profile
+var securityProfileParams = map[SecurityProfile][]string{
+	SecurityProfileWPAWPA2Personal: {
+		OptionKeyWirelessSecurityKeyManagement, KeyManagementWPA2_3Personal,
+		OptionKeyWirelessSecurityProto, strings.Join([]string{ProtoAllowWPA, ProtoAllowWPA2RSN}, ","),
+		OptionKeyWirelessSecurityPairwise, strings.Join([]string{EncryptionAlgTkip, EncryptionAlgCcmp}, ","),
+		OptionKeyWirelessSecurityGroup, EncryptionAlgTkip,
+		OptionKeyWirelessSecurityPMF, WirelessPMFDisable,
+	},
+	SecurityProfileWPA2Personal: {
+		OptionKeyWirelessSecurityKeyManagement, KeyManagementWPA2_3Personal,
+		OptionKeyWirelessSecurityProto, ProtoAllowWPA2RSN,
+		OptionKeyWirelessSecurityPairwise, EncryptionAlgCcmp,
+		OptionKeyWirelessSecurityGroup, EncryptionAlgCcmp,
+		// SAE is negotiated by wpa-psk unless PMF is disabled
+		OptionKeyWirelessSecurityPMF, WirelessPMFDisable,
+	},
+	SecurityProfileWPA2WPA3Personal: {
+		OptionKeyWirelessSecurityKeyManagement, KeyManagementWPA2_3Personal,
+		OptionKeyWirelessSecurityProto, ProtoAllowWPA2RSN,
+		OptionKeyWirelessSecurityPairwise, EncryptionAlgCcmp,
+		OptionKeyWirelessSecurityGroup, EncryptionAlgCcmp,
+		OptionKeyWirelessSecurityPMF, WirelessPMFOptional,
+	},
+	SecurityProfileWPA3Personal: {
+		OptionKeyWirelessSecurityKeyManagement, KeyManagementWPA3Personal,
+		OptionKeyWirelessSecurityProto, ProtoAllowWPA2RSN,
+		OptionKeyWirelessSecurityPairwise, EncryptionAlgCcmp,
+		OptionKeyWirelessSecurityGroup, EncryptionAlgCcmp,
+		OptionKeyWirelessSecurityPMF, WirelessPMFRequired,
+	},
+}
+
+func (s AccessPointSecurity) validate() error {
+	switch s.Profile {
+	case SecurityProfileOpen:
+		return nil
+	case SecurityProfileWPA2Enterprise, SecurityProfileWPA3Enterprise:
+		return ErrEnterpriseAccessPointUnsupported
+	}
+	if _, ok := securityProfileParams[s.Profile]; !ok {
+		return fmt.Errorf("unknown security profile %q", s.Profile)
+	}
+	if s.Profile == SecurityProfileWPA3Personal && s.PMF != "" && s.PMF != WirelessPMFRequired {
+		return fmt.Errorf("invalid PMF %q: WPA3 requires protected management frames", s.PMF)
+	}
+	return ValidatePassphrase(s.Password)
+}
+
+// Returns nmcli params of the security setting, none for open network
+func (s AccessPointSecurity) params() ([]string, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	if s.Profile == SecurityProfileOpen {
+		return []string{}, nil
+	}
+
+	params := append([]string{}, securityProfileParams[s.Profile]...)
+	if s.PMF != "" {
+		params = setParam(params, OptionKeyWirelessSecurityPMF, s.PMF)
+	}
+	return append(params, OptionKeyWirelessSecurityPassword, s.Password), nil
+}
+
+// Replaces value of the option in name and value pairs or appends it
+func setParam(params []string, name, value string) []string {
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i] == name {
+			params[i+1] = value
+			return params
+		}
+	}
+	return append(params, name, value)
+}
+
+// Validates WPA passphrase: 8 to 63 printable ASCII characters or 64 hex digits
+func ValidatePassphrase(password string) error {
+	if len(password) == 64 {
+		for _, r := range password {
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return fmt.Errorf("invalid password: 64 characters long password must be hexadecimal")
+			}
+		}
+		return nil
+	}
+	if len(password) < 8 || len(password) > 63 {
+		return fmt.Errorf("invalid password: must be from 8 to 63 characters long")
+	}
+	for _, r := range password {
+		if r < ' ' || r > '~' {
+			return fmt.Errorf("invalid password: must contain only printable ASCII characters")
+		}
+	}
+	return nil
+}
+
+// Validates SSID, which must be from 1 to 32 bytes long
+func ValidateSSID(ssid string) error {
+	if len(ssid) < 1 || len(ssid) > 32 {
+		return fmt.Errorf("invalid ssid: must be from 1 to 32 bytes long, got %d", len(ssid))
+	}
+	return nil
+}
+
+// Replaces security of the connection with specified profile
+func (c *WirelessConnection) SetSecurity(security AccessPointSecurity) error {
+	params, err := security.params()
+	if err != nil {
+		return err
+	}
+	if security.Profile == SecurityProfileOpen {
+		params = []string{removeSettingParam, SettingWirelessSecurity}
+	}
+	return c.setOptions(params...)
+}
+
+var wirelessPMFValues = []WirelessPMF{WirelessPMFDefault, WirelessPMFDisable, WirelessPMFOptional, WirelessPMFRequired}
+
+func (c *WirelessConnection) GetPMF() WirelessPMF {
+	return normalizeEnumOption(c.getOption(OptionKeyWirelessSecurityPMF), wirelessPMFValues)
+}
+
+// nmcli may print enum values as numbers or like "1 (disable)", names are
+// indexed by numeric values
+func normalizeEnumOption(value string, names []string) string {
+	if _, inner, ok := strings.Cut(value, "("); ok {
+		value = strings.TrimSuffix(inner, ")")
+	}
+	if i, err := strconv.Atoi(value); err == nil && i >= 0 && i < len(names) {
+		return names[i]
+	}
+	return value
+}
+
+// Detects security profile from connection options, key management is
+// returned as is when no profile matches
+func (c *WirelessConnection) GetSecurityProfile() SecurityProfile {
+	keyManagement := c.getOption(OptionKeyWirelessSecurityKeyManagement)
+	switch keyManagement {
+	case "":
+		return SecurityProfileOpen
+	case KeyManagementWPA3Personal:
+		return SecurityProfileWPA3Personal
+	case KeyManagementWPA2_3Enterprise:
+		return SecurityProfileWPA2Enterprise
+	case KeyManagementWPA3Enterprise:
+		return SecurityProfileWPA3Enterprise
+	case KeyManagementWPA2_3Personal:
+		if slices.Contains(splitList(c.getOption(OptionKeyWirelessSecurityProto)), ProtoAllowWPA) {
+			return SecurityProfileWPAWPA2Personal
+		}
+		if c.GetPMF() == WirelessPMFDisable {
+			return SecurityProfileWPA2Personal
+		}
+		return SecurityProfileWPA2WPA3Personal
+	}
+	return SecurityProfile(keyManagement)
+}
@@ -0,0 +1,76 @@
+// Downloads IEEE MA-L, MA-M and MA-S registries and writes them as one CSV
+// file with registry, assignment and organization name columns.
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+var sources = []string{
+	"https://standards-oui.ieee.org/oui/oui.csv",
+	"https://standards-oui.ieee.org/oui28/mam.csv",
+	"https://standards-oui.ieee.org/oui36/oui36.csv",
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: gen <output.csv>")
+		os.Exit(2)
+	}
+	records := [][]string{}
+	for _, url := range sources {
+		downloaded, err := download(url)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed download %s: %s\n", url, err)
+			os.Exit(1)
+		}
+		records = append(records, downloaded...)
+	}
+	sort.Slice(records, func(a, b int) bool { return records[a][1] < records[b][1] })
+
+	file, err := os.Create(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	_ = writer.Write([]string{"Registry", "Assignment", "Organization Name"})
+	_ = writer.WriteAll(records)
+	if err := writer.Error(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// IEEE files have Registry, Assignment, Organization Name and Organization
+// Address columns, the address isn't needed
+func download(url string) ([][]string, error) {
+	response, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	reader := csv.NewReader(response.Body)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	records := [][]string{}
+	for _, row := range rows[1:] {
+		if len(row) < 3 {
+			continue
+		}
+		records = append(records, []string{row[0], strings.ToUpper(row[1]), strings.TrimSpace(row[2])})
+	}
+	return records, nil
+}
@@ -0,0 +1,135 @@
+// Package oui finds vendors of network devices by MAC address in a registry
+// of IEEE MA-L (OUI), MA-M and MA-S assignments embedded in the library. The
+// registry shipped in the repository is a small seed of common vendors, run
+// `go generate ./oui` to replace it with the full IEEE registry.
+package oui
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	l "github.com/charmbracelet/log"
+)
+
+//go:generate go run ./internal/gen registry.csv
+
+var log *l.Logger
+
+func init() {
+	log = l.WithPrefix("CLI oui")
+}
+
+//go:embed registry.csv
+var registryData []byte
+
+type Registry = string
+
+const (
+	RegistryMAL Registry = "MA-L" // 24 bit prefix
+	RegistryMAM Registry = "MA-M" // 28 bit prefix
+	RegistryMAS Registry = "MA-S" // 36 bit prefix
+)
+
+// Prefix length in hex digits, longer assignments are looked up first
+var registryDigits = []struct {
+	registry Registry
+	digits   int
+}{
+	{RegistryMAS, 9},
+	{RegistryMAM, 7},
+	{RegistryMAL, 6},
+}
+
+type Assignment struct {
+	Registry Registry `json:"registry"`
+	// Hex digits of the prefix, e.g. "B827EB"
+	Prefix string `json:"prefix"`
+	Vendor string `json:"vendor"`
+}
+
+var registry struct {
+	sync.Once
+	assignments map[string]Assignment
+}
+
+func load() {
+	registry.assignments = map[string]Assignment{}
+	records, err := csv.NewReader(bytes.NewReader(registryData)).ReadAll()
+	if err != nil {
+		log.Error("Failed parse embedded OUI registry", "error", err)
+		return
+	}
+	for _, record := range records[1:] {
+		if len(record) < 3 {
+			continue
+		}
+		prefix := strings.ToUpper(record[1])
+		if !validPrefix(prefix) {
+			log.Warn("Skipping invalid OUI registry entry", "prefix", prefix)
+			continue
+		}
+		registry.assignments[prefix] = Assignment{Registry: record[0], Prefix: prefix, Vendor: strings.TrimSpace(record[2])}
+	}
+}
+
+// Returns assignment covering MAC address. Locally administered addresses
+// aren't assigned by IEEE and are never found.
+func Lookup(mac string) (*Assignment, bool) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != 6 || IsLocallyAdministered(hw) {
+		return nil, false
+	}
+	registry.Do(load)
+	digits := fmt.Sprintf("%X", []byte(hw))
+	for _, r := range registryDigits {
+		if assignment, ok := registry.assignments[digits[:r.digits]]; ok && assignment.Registry == r.registry {
+			return &assignment, true
+		}
+	}
+	return nil, false
+}
+
+// Returns vendor of MAC address, empty if it is unknown
+func Vendor(mac string) string {
+	if assignment, ok := Lookup(mac); ok {
+		return assignment.Vendor
+	}
+	return ""
+}
+
+// Locally administered addresses are set by software instead of the vendor.
+// Phones use random ones to avoid being tracked.
+func IsLocallyAdministered(mac net.HardwareAddr) bool {
+	return len(mac) > 0 && mac[0]&0x02 != 0
+}
+
+// Reports whether unicast MAC address is randomized, i.e. locally administered
+func IsRandomized(mac string) bool {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) == 0 || hw[0]&0x01 != 0 {
+		return false
+	}
+	return IsLocallyAdministered(hw)
+}
+
+// Number of assignments in the embedded registry, a handful for the seed
+// and tens of thousands for the full registry
+func Size() int {
+	registry.Do(load)
+	return len(registry.assignments)
+}
+
+// Hex digits of a prefix, e.g. "B827EB" or "70B3D5ABC"
+func validPrefix(prefix string) bool {
+	if len(prefix) != 6 && len(prefix) != 7 && len(prefix) != 9 {
+		return false
+	}
+	_, err := strconv.ParseUint(prefix, 16, 64)
+	return err == nil
+}
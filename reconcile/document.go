@@ -0,0 +1,192 @@
+// Package reconcile brings router network configuration to the state
+// described by a YAML document.
+//
+//	wan:
+//	  device: eth0
+//	  method: dhcp
+//	lan:
+//	  bridge: br-lan
+//	  ports: [eth1, eth2]
+//	  address: 192.168.1.1/24
+//	accessPoints:
+//	  - device: wlan0
+//	    ssid: Home
+//	    security: wpa2-wpa3-personal
+//	    password: secret-passphrase
+//	    bridged: true
+//	dns: [1.1.1.1, 8.8.8.8]
+//	services:
+//	  - name: ssh
+//	    enabled: true
+package reconcile
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+
+	l "github.com/charmbracelet/log"
+	"github.com/zarinit-routers/cli/nmcli"
+	"github.com/zarinit-routers/cli/systemctl"
+	"go.yaml.in/yaml/v3"
+)
+
+var log *l.Logger
+
+func init() {
+	log = l.WithPrefix("CLI reconcile")
+}
+
+// Names of connection profiles managed by reconciler
+const (
+	WANConnectionName     = "wan"
+	LANConnectionName     = "lan"
+	LANPortConnectionName = "lan-port-" // followed by device name
+	APConnectionName      = "ap-"       // followed by device name, unless name is set
+)
+
+const DefaultLANBridge = "br-lan"
+
+type WANMethod = string
+
+const (
+	WANMethodDHCP   WANMethod = "dhcp"
+	WANMethodStatic WANMethod = "static"
+)
+
+// Desired state of the router
+type Document struct {
+	WAN          *WAN          `yaml:"wan"`
+	LAN          *LAN          `yaml:"lan"`
+	AccessPoints []AccessPoint `yaml:"accessPoints"`
+	// DNS servers used instead of ones received on WAN
+	DNS      []string  `yaml:"dns"`
+	Services []Service `yaml:"services"`
+}
+
+// Ethernet uplink
+type WAN struct {
+	Device string    `yaml:"device"`
+	Method WANMethod `yaml:"method"`
+	// Address with prefix and gateway are required for static method
+	Address string `yaml:"address"`
+	Gateway string `yaml:"gateway"`
+}
+
+// Bridge of ethernet ports sharing router address and DHCP with clients
+type LAN struct {
+	Bridge  string   `yaml:"bridge"`
+	Ports   []string `yaml:"ports"`
+	Address string   `yaml:"address"`
+}
+
+type AccessPoint struct {
+	// Connection name, defaults to "ap-" followed by device name
+	Name     string                `yaml:"name"`
+	Device   string                `yaml:"device"`
+	SSID     string                `yaml:"ssid"`
+	Security nmcli.SecurityProfile `yaml:"security"`
+	Password string                `yaml:"password"`
+	Band     nmcli.WirelessBand    `yaml:"band"`
+	Channel  int                   `yaml:"channel"`
+	Hidden   bool                  `yaml:"hidden"`
+	// Attach to LAN bridge instead of sharing own subnet
+	Bridged bool `yaml:"bridged"`
+}
+
+// systemd service, enabled services are started and disabled ones stopped
+type Service struct {
+	Name    string `yaml:"name"`
+	Enabled bool   `yaml:"enabled"`
+}
+
+func Load(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parses and validates document, unknown fields are errors to catch typos
+func Parse(data []byte) (*Document, error) {
+	doc := &Document{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(doc); err != nil {
+		return nil, fmt.Errorf("failed parse document: %s", err)
+	}
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (d *Document) Validate() error {
+	if d.WAN != nil {
+		if d.WAN.Device == "" {
+			return fmt.Errorf("wan: device is required")
+		}
+		switch d.WAN.Method {
+		case "", WANMethodDHCP:
+		case WANMethodStatic:
+			if _, _, err := net.ParseCIDR(d.WAN.Address); err != nil {
+				return fmt.Errorf("wan: invalid address %q: %s", d.WAN.Address, err)
+			}
+			if net.ParseIP(d.WAN.Gateway) == nil {
+				return fmt.Errorf("wan: invalid gateway %q", d.WAN.Gateway)
+			}
+		default:
+			return fmt.Errorf("wan: unknown method %q", d.WAN.Method)
+		}
+	}
+	if d.LAN != nil {
+		if _, _, err := net.ParseCIDR(d.LAN.Address); err != nil {
+			return fmt.Errorf("lan: invalid address %q: %s", d.LAN.Address, err)
+		}
+	}
+
+	names := map[string]bool{}
+	for i, ap := range d.AccessPoints {
+		if ap.Device == "" {
+			return fmt.Errorf("accessPoints[%d]: device is required", i)
+		}
+		if ap.Bridged && d.LAN == nil {
+			return fmt.Errorf("accessPoints[%d]: bridged access point requires lan", i)
+		}
+		if names[ap.connectionName()] {
+			return fmt.Errorf("accessPoints[%d]: duplicate name %q", i, ap.connectionName())
+		}
+		names[ap.connectionName()] = true
+		if err := nmcli.ValidateSSID(ap.SSID); err != nil {
+			return fmt.Errorf("accessPoints[%d]: %s", i, err)
+		}
+	}
+
+	for _, server := range d.DNS {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("dns: invalid address %q", server)
+		}
+	}
+	for i, service := range d.Services {
+		if !systemctl.IsValidServiceName(service.Name) {
+			return fmt.Errorf("services[%d]: invalid name %q", i, service.Name)
+		}
+	}
+	return nil
+}
+
+func (a *AccessPoint) connectionName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return APConnectionName + a.Device
+}
+
+func (l *LAN) bridge() string {
+	if l.Bridge != "" {
+		return l.Bridge
+	}
+	return DefaultLANBridge
+}
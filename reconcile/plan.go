@@ -0,0 +1,314 @@
+package reconcile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zarinit-routers/cli/nmcli"
+	"github.com/zarinit-routers/cli/systemctl"
+)
+
+type Action = string
+
+const (
+	ActionNone   Action = "none"
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+)
+
+// Difference between desired and live state of one resource
+type Change struct {
+	// Kind and name, e.g. "connection wan"
+	Resource string `json:"resource"`
+	Action   Action `json:"action"`
+	// What is going to change, e.g. names of options
+	Details []string `json:"details"`
+	// Error of reading live state, the change is skipped on apply
+	Error error `json:"-"`
+	// Message of Error for JSON
+	ErrorMessage string `json:"error,omitempty"`
+
+	apply func() error
+}
+
+// Outcome of applying one change
+type Result struct {
+	Resource string `json:"resource"`
+	Action   Action `json:"action"`
+	Error    error  `json:"-"`
+	// Message of Error for JSON
+	ErrorMessage string `json:"error,omitempty"`
+}
+
+func (r *Result) Ok() bool {
+	return r.Error == nil
+}
+
+// Changes are ordered the way they must be applied, e.g. bridge before ports
+type Plan struct {
+	Changes []Change `json:"changes"`
+}
+
+type resource interface {
+	name() string
+	plan() (Change, error)
+}
+
+// Compares document with live state
+func NewPlan(doc *Document) (*Plan, error) {
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	plan := &Plan{Changes: []Change{}}
+	for _, r := range doc.resources() {
+		change, err := r.plan()
+		if err != nil {
+			change = Change{Resource: r.name(), Error: err, ErrorMessage: err.Error()}
+		}
+		change.Resource = r.name()
+		plan.Changes = append(plan.Changes, change)
+	}
+	return plan, nil
+}
+
+func (p *Plan) HasChanges() bool {
+	for _, change := range p.Changes {
+		if change.Action != ActionNone || change.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Human readable plan, one line per resource
+//
+//	~ connection lan: ipv4.addresses
+//	+ connection wan
+//	  service ssh
+//	! access point ap-wlan0: failed read state: ...
+func (p *Plan) String() string {
+	var b strings.Builder
+	for _, change := range p.Changes {
+		switch {
+		case change.Error != nil:
+			fmt.Fprintf(&b, "! %s: %s\n", change.Resource, change.Error)
+		case change.Action == ActionCreate:
+			fmt.Fprintf(&b, "+ %s\n", change.Resource)
+		case change.Action == ActionUpdate && len(change.Details) > 0:
+			fmt.Fprintf(&b, "~ %s: %s\n", change.Resource, strings.Join(change.Details, ", "))
+		case change.Action == ActionUpdate:
+			fmt.Fprintf(&b, "~ %s\n", change.Resource)
+		default:
+			fmt.Fprintf(&b, "  %s\n", change.Resource)
+		}
+	}
+	return b.String()
+}
+
+// Applies changes in order. Failure of one resource doesn't stop others, so
+// results must be checked one by one.
+func (p *Plan) Apply() []Result {
+	results := []Result{}
+	for _, change := range p.Changes {
+		result := Result{Resource: change.Resource, Action: change.Action, Error: change.Error}
+		if change.Error == nil && change.Action != ActionNone {
+			log.Info("Applying change", "resource", change.Resource, "action", change.Action, "details", change.Details)
+			result.Error = change.apply()
+		}
+		if result.Error != nil {
+			result.ErrorMessage = result.Error.Error()
+			log.Error("Failed reconcile resource", "resource", change.Resource, "error", result.Error)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Plans and applies document, applying the same document again changes nothing
+func Converge(doc *Document) ([]Result, error) {
+	plan, err := NewPlan(doc)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("Reconcile plan\n" + plan.String())
+	return plan.Apply(), nil
+}
+
+func (d *Document) resources() []resource {
+	resources := []resource{}
+	if d.WAN != nil {
+		resources = append(resources, &connectionResource{d.wanSpec()})
+	}
+	if d.LAN != nil {
+		resources = append(resources, &connectionResource{d.lanSpec()})
+		for _, port := range d.LAN.Ports {
+			resources = append(resources, &connectionResource{d.lanPortSpec(port)})
+		}
+	}
+	for _, ap := range d.AccessPoints {
+		resources = append(resources, &accessPointResource{d.accessPointSpec(ap)})
+	}
+	for _, service := range d.Services {
+		resources = append(resources, &serviceResource{service})
+	}
+	return resources
+}
+
+func (d *Document) wanSpec() nmcli.ConnectionSpec {
+	options := []string{nmcli.OptionKeyAutoconnect, nmcli.TrueValue}
+	if d.WAN.Method == WANMethodStatic {
+		options = append(options,
+			nmcli.OptionKeyIP4Method, nmcli.ConnectionIP4MethodManual,
+			nmcli.OptionKeyIP4Addresses, d.WAN.Address,
+			nmcli.OptionKeyIP4Gateway, d.WAN.Gateway,
+		)
+	} else {
+		options = append(options,
+			nmcli.OptionKeyIP4Method, nmcli.ConnectionIP4MethodAuto,
+			nmcli.OptionKeyIP4Addresses, "",
+			nmcli.OptionKeyIP4Gateway, "",
+		)
+	}
+	ignoreAutoDNS := "no"
+	if len(d.DNS) > 0 {
+		ignoreAutoDNS = nmcli.TrueValue
+	}
+	options = append(options,
+		nmcli.OptionKeyDNSAddresses, strings.Join(d.DNS, ","),
+		nmcli.OptionKeyIP4IgnoreAutoDNS, ignoreAutoDNS,
+	)
+	return nmcli.ConnectionSpec{
+		Name:    WANConnectionName,
+		Type:    nmcli.ConnectionTypeEthernet,
+		Device:  d.WAN.Device,
+		Options: options,
+	}
+}
+
+func (d *Document) lanSpec() nmcli.ConnectionSpec {
+	return nmcli.ConnectionSpec{
+		Name:   LANConnectionName,
+		Type:   nmcli.ConnectionTypeBridge,
+		Device: d.LAN.bridge(),
+		Options: []string{
+			nmcli.OptionKeyAutoconnect, nmcli.TrueValue,
+			nmcli.OptionKeyIP4Method, nmcli.ConnectionIP4MethodShared,
+			nmcli.OptionKeyIP4Addresses, d.LAN.Address,
+		},
+	}
+}
+
+func (d *Document) lanPortSpec(port string) nmcli.ConnectionSpec {
+	return nmcli.ConnectionSpec{
+		Name:   LANPortConnectionName + port,
+		Type:   nmcli.ConnectionTypeEthernet,
+		Device: port,
+		Options: []string{
+			nmcli.OptionKeyAutoconnect, nmcli.TrueValue,
+			nmcli.OptionKeyMaster, d.LAN.bridge(),
+			nmcli.OptionKeySlaveType, string(nmcli.ConnectionTypeBridge),
+		},
+	}
+}
+
+func (d *Document) accessPointSpec(ap AccessPoint) nmcli.AccessPointSpec {
+	security := ap.Security
+	if security == "" {
+		security = nmcli.SecurityProfileWPA2WPA3Personal
+	}
+	spec := nmcli.AccessPointSpec{
+		Name:     ap.connectionName(),
+		Device:   ap.Device,
+		SSID:     ap.SSID,
+		Security: nmcli.AccessPointSecurity{Profile: security, Password: ap.Password},
+		Band:     ap.Band,
+		Channel:  ap.Channel,
+		Hidden:   ap.Hidden,
+	}
+	if ap.Bridged {
+		spec.Bridge = d.LAN.bridge()
+	}
+	return spec
+}
+
+type connectionResource struct {
+	spec nmcli.ConnectionSpec
+}
+
+func (r *connectionResource) name() string {
+	return "connection " + r.spec.Name
+}
+
+func (r *connectionResource) plan() (Change, error) {
+	changes, err := nmcli.PlanConnection(r.spec)
+	if err != nil {
+		return Change{}, err
+	}
+	return Change{
+		Action:  changeAction(changes.Created, changes.HasChanges()),
+		Details: changes.Changed,
+		apply: func() error {
+			_, err := nmcli.EnsureConnection(r.spec)
+			return err
+		},
+	}, nil
+}
+
+type accessPointResource struct {
+	spec nmcli.AccessPointSpec
+}
+
+func (r *accessPointResource) name() string {
+	return "access point " + r.spec.Name
+}
+
+func (r *accessPointResource) plan() (Change, error) {
+	changes, err := nmcli.PlanAccessPoint(r.spec)
+	if err != nil {
+		return Change{}, err
+	}
+	return Change{
+		Action:  changeAction(changes.Created, changes.HasChanges()),
+		Details: changes.Changed,
+		apply: func() error {
+			_, err := nmcli.EnsureAccessPoint(r.spec)
+			return err
+		},
+	}, nil
+}
+
+func changeAction(created, changed bool) Action {
+	switch {
+	case created:
+		return ActionCreate
+	case changed:
+		return ActionUpdate
+	}
+	return ActionNone
+}
+
+type serviceResource struct {
+	service Service
+}
+
+func (r *serviceResource) name() string {
+	return "service " + r.service.Name
+}
+
+func (r *serviceResource) plan() (Change, error) {
+	s := systemctl.Service(r.service.Name)
+	if !systemctl.ServiceExists(s) {
+		return Change{}, fmt.Errorf("no such service")
+	}
+	enabled, active := systemctl.IsEnabled(s), systemctl.IsActive(s)
+	change := Change{Action: ActionNone}
+	switch {
+	case r.service.Enabled && (!enabled || !active):
+		change.Action, change.Details = ActionUpdate, []string{"enable"}
+		change.apply = func() error { return systemctl.Enable(s) }
+	case !r.service.Enabled && (enabled || active):
+		change.Action, change.Details = ActionUpdate, []string{"disable"}
+		change.apply = func() error { return systemctl.Disable(s) }
+	}
+	return change, nil
+}
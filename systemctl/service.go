@@ -18,6 +18,10 @@ func init() {
 
 type Service string
 
+func IsValidServiceName(name string) bool {
+	return compiledRegex.MatchString(name)
+}
+
 func NewService(name string) Service {
 	match := compiledRegex.MatchString(name)
 	if !match {
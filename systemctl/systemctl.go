@@ -13,7 +13,8 @@ const SystemctlExecutable = "systemctl"
 const ExitCodeInactive = 3
 
 const (
-	StatusActive = "active"
+	StatusActive  = "active"
+	StatusEnabled = "enabled"
 )
 
 var log *l.Logger
@@ -74,6 +75,14 @@ func IsActive(s Service) bool {
 	strOutput := strings.TrimSpace(string(output))
 	return strOutput == StatusActive
 }
+func IsEnabled(s Service) bool {
+	output, err := cli.Execute(SystemctlExecutable, "is-enabled", string(s))
+	if err != nil {
+		// Disabled services make is-enabled exit with non-zero code
+		return false
+	}
+	return strings.TrimSpace(string(output)) == StatusEnabled
+}
 func Restart(s Service) error {
 	err := cli.ExecuteErr(SystemctlExecutable, "restart", string(s))
 	if err != nil {